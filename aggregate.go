@@ -0,0 +1,126 @@
+package mysql
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strconv"
+)
+
+// AggregateFunc is a function of an Aggregate.
+type AggregateFunc string
+
+const (
+	AggregateCount         AggregateFunc = "count"
+	AggregateCountDistinct AggregateFunc = "countDistinct"
+	AggregateSum           AggregateFunc = "sum"
+	AggregateAvg           AggregateFunc = "avg"
+	AggregateMin           AggregateFunc = "min"
+	AggregateMax           AggregateFunc = "max"
+)
+
+var aggregateFuncs = map[AggregateFunc]string{
+	AggregateCount:         "COUNT(%s)",
+	AggregateCountDistinct: "COUNT(DISTINCT %s)",
+	AggregateSum:           "SUM(%s)",
+	AggregateAvg:           "AVG(%s)",
+	AggregateMin:           "MIN(%s)",
+	AggregateMax:           "MAX(%s)",
+}
+
+// Aggregate is computed over all rows matching a FindPage query and reported
+// under Name in the page summary. Column may be empty for AggregateCount.
+type Aggregate struct {
+	Name   string        `json:"name"`
+	Func   AggregateFunc `json:"func"`
+	Column string        `json:"column"`
+}
+
+type AggregateError struct {
+	Aggregate Aggregate
+	Err       error
+}
+
+func (e *AggregateError) Error() string {
+	return fmt.Sprintf("aggregate %s %s(%s): %v", e.Aggregate.Name, e.Aggregate.Func, e.Aggregate.Column, e.Err)
+}
+
+func (e *AggregateError) Unwrap() error {
+	return e.Err
+}
+
+// summarize computes the aggregates of queryOption with the conditions and
+// joins of query, ignoring its sort and page.
+func summarize(model interface{}, query *gorm.DB, queryOption *QueryOption) (map[string]interface{}, error) {
+	s, err := parseSchema(query, model)
+	if err != nil {
+		return nil, WithStack(err)
+	}
+	table := ""
+	if queryOption.Table == "" {
+		table = s.Table
+	}
+	sub := countBuilder(query, queryOption)
+	if groupedOrDistinct(query, queryOption) {
+		table = ""
+	}
+
+	selects := make([]string, 0, len(queryOption.Aggregates))
+	for i, aggregate := range queryOption.Aggregates {
+		format, ok := aggregateFuncs[aggregate.Func]
+		if !ok || aggregate.Name == "" {
+			return nil, WithStack(&AggregateError{Aggregate: aggregate, Err: ErrorAggregate})
+		}
+		column := "*"
+		switch {
+		case aggregate.Column == "" || aggregate.Column == "*":
+			if aggregate.Func != AggregateCount {
+				return nil, WithStack(&AggregateError{Aggregate: aggregate, Err: ErrorAggregate})
+			}
+		case queryOption.IgnoreFilterWhitelist:
+			column = query.Statement.Quote(aggregate.Column)
+		default:
+			field := lookupField(s, aggregate.Column)
+			if field == nil {
+				return nil, WithStack(&AggregateError{Aggregate: aggregate, Err: ErrorAggregate})
+			}
+			column = query.Statement.Quote(clause.Column{Table: table, Name: field.DBName})
+		}
+		selects = append(selects, fmt.Sprintf(format, column)+" AS "+aggregateAlias(i))
+	}
+
+	row := map[string]interface{}{}
+	if err := sub.Select(selects).Scan(&row).Error; err != nil {
+		return nil, WithStack(err)
+	}
+	summary := make(map[string]interface{}, len(queryOption.Aggregates))
+	for i, aggregate := range queryOption.Aggregates {
+		summary[aggregate.Name] = aggregateValue(row[aggregateAlias(i)])
+	}
+	return summary, nil
+}
+
+// aggregateValue converts the text the driver returns for SUM, AVG and for
+// the columns of a derived table to a number, other values are kept as text.
+func aggregateValue(value interface{}) interface{} {
+	var text string
+	switch v := value.(type) {
+	case []byte:
+		text = string(v)
+	case string:
+		text = v
+	default:
+		return value
+	}
+	if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(text, 64); err == nil {
+		return f
+	}
+	return text
+}
+
+func aggregateAlias(i int) string {
+	return "aggregate_" + strconv.Itoa(i)
+}
@@ -0,0 +1,111 @@
+package mysql
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestSummarizeSQL(t *testing.T) {
+	cases := []struct {
+		name string
+		opts []Option
+		sql  string
+		err  error
+	}{
+		{
+			name: "aggregates",
+			opts: []Option{
+				WithFilters(map[string]interface{}{"name": "a"}),
+				WithPage(2, 10, "amount desc"),
+				WithAggregate("total", AggregateCount, ""),
+				WithAggregate("amount", AggregateSum, "amount"),
+				WithAggregate("codes", AggregateCountDistinct, "code"),
+			},
+			sql: "SELECT COUNT(*) AS aggregate_0,SUM(`test_orders`.`amount`) AS aggregate_1,COUNT(DISTINCT `test_orders`.`code`) AS aggregate_2 FROM `test_orders` WHERE `test_orders`.`name` = ? ",
+		},
+		{
+			name: "grouped",
+			opts: []Option{WithSelect("name, amount"), WithGroup("name, amount"), WithAggregate("amount", AggregateAvg, "amount")},
+			sql:  "SELECT AVG(`amount`) AS aggregate_0 FROM (SELECT name, amount FROM `test_orders` GROUP BY name, amount ) AS count_rows",
+		},
+		{
+			name: "ignore whitelist",
+			opts: []Option{WithIgnoreFilterWhitelist(), WithAggregate("max", AggregateMax, "password")},
+			sql:  "SELECT MAX(`password`) AS aggregate_0 FROM `test_orders` ",
+		},
+		{
+			name: "hidden column",
+			opts: []Option{WithAggregate("max", AggregateMax, "password")},
+			err:  ErrorAggregate,
+		},
+		{
+			name: "unknown column",
+			opts: []Option{WithAggregate("max", AggregateMax, "unknown")},
+			err:  ErrorAggregate,
+		},
+		{
+			name: "unknown func",
+			opts: []Option{WithAggregate("median", AggregateFunc("median"), "amount")},
+			err:  ErrorAggregate,
+		},
+		{
+			name: "sum without column",
+			opts: []Option{WithAggregate("amount", AggregateSum, "")},
+			err:  ErrorAggregate,
+		},
+		{
+			name: "empty name",
+			opts: []Option{WithAggregate("", AggregateCount, "")},
+			err:  ErrorAggregate,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			db := newDryRunDB(t)
+			var sql string
+			if err := db.Callback().Row().After("gorm:row").Register("test:capture", func(g *gorm.DB) {
+				sql = g.Statement.SQL.String()
+			}); err != nil {
+				t.Fatal(err)
+			}
+			query, queryOpt := db.readQueryBuilder(&testOrder{}, c.opts...)
+			_, err := summarize(&testOrder{}, query, queryOpt)
+			if c.err != nil {
+				if !errors.Is(err, c.err) {
+					t.Fatalf("err = %v, want %v", err, c.err)
+				}
+				return
+			}
+			// the dry run DB can't scan, only the SQL is of interest
+			if err != nil && !errors.Is(err, gorm.ErrDryRunModeUnsupported) {
+				t.Fatal(err)
+			}
+			if sql != c.sql {
+				t.Errorf("sql = %s\nwant  %s", sql, c.sql)
+			}
+		})
+	}
+}
+
+func TestAggregateValue(t *testing.T) {
+	cases := []struct {
+		value interface{}
+		want  interface{}
+	}{
+		{value: int64(3), want: int64(3)},
+		{value: nil, want: nil},
+		{value: []byte("12"), want: int64(12)},
+		{value: []byte("12.50"), want: 12.5},
+		{value: "7", want: int64(7)},
+		{value: "2024-01-02", want: "2024-01-02"},
+		{value: []byte("abc"), want: "abc"},
+	}
+	for _, c := range cases {
+		if got := aggregateValue(c.value); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("aggregateValue(%#v) = %#v, want %#v", c.value, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"strconv"
+	"sync"
+)
+
+// CountStrategy selects how FindPage obtains the total.
+type CountStrategy string
+
+const (
+	// CountExact runs COUNT(*), the default
+	CountExact CountStrategy = "exact"
+	// CountNone skips counting
+	CountNone CountStrategy = "none"
+	// CountHasNext fetches one extra row to report whether a next page exists
+	CountHasNext CountStrategy = "hasNext"
+	// CountEstimate uses the row estimates of information_schema for unfiltered
+	// queries and of EXPLAIN otherwise
+	CountEstimate CountStrategy = "estimate"
+)
+
+func estimateCount(model interface{}, query *gorm.DB, queryOption *QueryOption) (int64, error) {
+	sub := countBuilder(query, queryOption)
+	_, where := sub.Statement.Clauses["WHERE"]
+	// grouped and distinct queries are wrapped in a derived table, EXPLAIN
+	// estimates the rows of that table
+	if !where && !groupedOrDistinct(query, queryOption) && len(sub.Statement.Joins) == 0 {
+		table := queryOption.Table
+		if table == "" {
+			s, err := parseSchema(query, model)
+			if err != nil {
+				return 0, WithStack(err)
+			}
+			table = s.Table
+		}
+		var rows int64
+		if err := query.Session(&gorm.Session{NewDB: true}).Raw(
+			"SELECT IFNULL(TABLE_ROWS, 0) FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?",
+			table,
+		).Scan(&rows).Error; err != nil {
+			return 0, WithStack(err)
+		}
+		return rows, nil
+	}
+
+	stmt := sub.Session(&gorm.Session{DryRun: true}).Find(&[]map[string]interface{}{}).Statement
+	plan := make([]map[string]interface{}, 0)
+	if err := query.Session(&gorm.Session{NewDB: true}).Raw("EXPLAIN "+stmt.SQL.String(), stmt.Vars...).Scan(&plan).Error; err != nil {
+		return 0, WithStack(err)
+	}
+	if len(plan) == 0 {
+		return 0, nil
+	}
+	// the first row is the driving table, filtered is the percentage of its
+	// rows left by the conditions
+	rows := planNumber(plan[0]["rows"])
+	if filtered := planNumber(plan[0]["filtered"]); filtered > 0 {
+		rows = rows * filtered / 100
+	}
+	return int64(rows), nil
+}
+
+func planNumber(value interface{}) float64 {
+	if value == nil {
+		return 0
+	}
+	if b, ok := value.([]byte); ok {
+		value = string(b)
+	}
+	f, _ := strconv.ParseFloat(fmt.Sprint(value), 64)
+	return f
+}
+
+// findAndCount runs listQuery and the count query of query concurrently, the
+// first failure cancels the other query.
+func findAndCount(list interface{}, listQuery *gorm.DB, query *gorm.DB, queryOption *QueryOption) (int64, error) {
+	ctx, cancel := context.WithCancel(contextOf(query))
+	defer cancel()
+	listQuery = listQuery.WithContext(ctx)
+	countQuery := countBuilder(query, queryOption).WithContext(ctx)
+
+	var (
+		wg    sync.WaitGroup
+		total int64
+		errs  = make([]error, 2)
+	)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		if errs[0] = listQuery.Find(list).Error; errs[0] != nil {
+			cancel()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		if errs[1] = countQuery.Count(&total).Error; errs[1] != nil {
+			cancel()
+		}
+	}()
+	wg.Wait()
+
+	// drop the cancellation caused by the other query failing
+	if errs[0] != nil && errs[1] != nil {
+		for i, err := range errs {
+			if errors.Is(err, context.Canceled) && contextOf(query).Err() == nil {
+				errs[i] = nil
+			}
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return 0, WithStack(err)
+	}
+	return total, nil
+}
@@ -0,0 +1,87 @@
+package mysql
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestEstimateCountSource(t *testing.T) {
+	cases := []struct {
+		name   string
+		opts   []Option
+		prefix string
+	}{
+		{name: "unfiltered", prefix: "SELECT IFNULL(TABLE_ROWS, 0) FROM information_schema.TABLES"},
+		{name: "filtered", opts: []Option{WithFilters(map[string]interface{}{"name": "a"})}, prefix: "EXPLAIN SELECT"},
+		{name: "grouped", opts: []Option{WithSelect("name"), WithGroup("name")}, prefix: "EXPLAIN SELECT * FROM (SELECT"},
+		{name: "distinct", opts: []Option{WithSelect("distinct name")}, prefix: "EXPLAIN SELECT * FROM (SELECT"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			db := newDryRunDB(t)
+			var sql string
+			if err := db.Callback().Row().After("gorm:row").Register("test:capture", func(g *gorm.DB) {
+				sql = g.Statement.SQL.String()
+			}); err != nil {
+				t.Fatal(err)
+			}
+			query, queryOpt := db.readQueryBuilder(&testOrder{}, c.opts...)
+			// the dry run DB can't scan, only the SQL is of interest
+			if _, err := estimateCount(&testOrder{}, query, queryOpt); err != nil && !errors.Is(err, gorm.ErrDryRunModeUnsupported) {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(sql, c.prefix) {
+				t.Errorf("sql = %s\nwant prefix %s", sql, c.prefix)
+			}
+		})
+	}
+}
+
+func TestCountBuilder(t *testing.T) {
+	cases := []struct {
+		name string
+		opts []Option
+		sql  string
+	}{
+		{
+			name: "plain",
+			opts: []Option{WithFilters(map[string]interface{}{"name": "a"}), WithPage(3, 10, "")},
+			sql:  "SELECT count(*) FROM `test_orders` WHERE `test_orders`.`name` = ? ",
+		},
+		{
+			name: "grouped",
+			opts: []Option{WithSelect("name"), WithGroup("name"), WithPage(3, 10, "")},
+			sql:  "SELECT count(*) FROM (SELECT `name` FROM `test_orders` GROUP BY `name` ) AS count_rows",
+		},
+		{
+			name: "distinct",
+			opts: []Option{WithSelect("distinct name"), WithPage(3, 10, "")},
+			sql:  "SELECT count(*) FROM (SELECT distinct name FROM `test_orders` ) AS count_rows",
+		},
+		{
+			name: "select join left out",
+			opts: []Option{
+				WithJoin("JOIN test_users ON test_users.id = test_orders.user_id"),
+				WithSelectJoin("LEFT JOIN test_companies ON test_companies.id = test_users.company_id"),
+			},
+			sql: "SELECT count(*) FROM `test_orders` JOIN test_users ON test_users.id = test_orders.user_id ",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			db := newDryRunDB(t)
+			query, queryOpt := db.readQueryBuilder(&testOrder{}, c.opts...)
+			var total int64
+			result := countBuilder(query, queryOpt).Count(&total)
+			if result.Error != nil {
+				t.Fatal(result.Error)
+			}
+			if sql := result.Statement.SQL.String(); sql != c.sql {
+				t.Errorf("sql = %s\nwant  %s", sql, c.sql)
+			}
+		})
+	}
+}
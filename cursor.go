@@ -0,0 +1,196 @@
+package mysql
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+	"reflect"
+	"strings"
+)
+
+type cursor struct {
+	Prev   bool              `json:"p,omitempty"`
+	Sort   string            `json:"s,omitempty"`
+	Values []json.RawMessage `json:"v"`
+}
+
+func encodeCursor(c *cursor) (string, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", WithStack(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(value string, sort string, fields []*SortField) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, WithStack(ErrorCursorInvalid)
+	}
+	c := &cursor{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, WithStack(ErrorCursorInvalid)
+	}
+	if c.Sort != sort || len(c.Values) != len(fields) {
+		return nil, WithStack(ErrorCursorInvalid)
+	}
+	return c, nil
+}
+
+// cursorSortFields parses sort and appends the primary key as tiebreak, in the
+// direction of the last field like defaultSort uses descending by default.
+func cursorSortFields(s *schema.Schema, sort string) ([]*SortField, error) {
+	fields, err := ParseSort(s, sort)
+	if err != nil {
+		return nil, err
+	}
+	pk := s.PrioritizedPrimaryField
+	if pk == nil {
+		return nil, WithStack(ErrorPrimaryKeyUnset)
+	}
+	desc := true
+	for _, field := range fields {
+		if field.Nulls != "" {
+			return nil, WithStack(&SortError{Sort: sort, Err: ErrorCursorSortNulls})
+		}
+		if field.Field == pk {
+			return fields, nil
+		}
+		desc = field.Desc
+	}
+	return append(fields, &SortField{Field: pk, Desc: desc}), nil
+}
+
+// keysetCondition renders (f1 > v1) OR (f1 = v1 AND f2 > v2) OR ..., with <
+// for descending fields, so rows after the cursor row are selected.
+func keysetCondition(query *gorm.DB, table string, fields []*SortField, values []interface{}, prev bool) (string, []interface{}) {
+	ors := make([]string, 0, len(fields))
+	args := make([]interface{}, 0)
+	for i, field := range fields {
+		ands := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			ands = append(ands, query.Statement.Quote(clause.Column{Table: table, Name: fields[j].Field.DBName})+" = ?")
+			args = append(args, values[j])
+		}
+		op := " > ?"
+		if field.Desc != prev {
+			op = " < ?"
+		}
+		ands = append(ands, query.Statement.Quote(clause.Column{Table: table, Name: field.Field.DBName})+op)
+		args = append(args, values[i])
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+	return "(" + strings.Join(ors, " OR ") + ")", args
+}
+
+func cursorOf(ctx context.Context, row reflect.Value, sort string, fields []*SortField, prev bool) (string, error) {
+	c := &cursor{Prev: prev, Sort: sort, Values: make([]json.RawMessage, 0, len(fields))}
+	for _, field := range fields {
+		data, err := json.Marshal(field.Field.ReflectValueOf(ctx, reflect.Indirect(row)).Interface())
+		if err != nil {
+			return "", WithStack(err)
+		}
+		c.Values = append(c.Values, data)
+	}
+	return encodeCursor(c)
+}
+
+// FindCursorPage pages list by keyset instead of LIMIT/OFFSET, see
+// WithCursorPage. Rows must not have NULL values in the sort columns. It
+// can't be combined with WithSort, WithPage or WithOffset.
+func (db *DB) FindCursorPage(list interface{}, opts ...Option) (*Cursors, error) {
+	listT := reflect.TypeOf(list)
+	if listT.Kind() != reflect.Ptr || listT.Elem().Kind() != reflect.Slice {
+		return nil, WithStack(ErrorModel)
+	}
+	if !(listT.Elem().Elem().Kind() == reflect.Struct || (listT.Elem().Elem().Kind() == reflect.Ptr && listT.Elem().Elem().Elem().Kind() == reflect.Struct)) {
+		return nil, WithStack(ErrorModel)
+	}
+
+	elem := listT.Elem().Elem()
+	if elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+
+	model := reflect.New(elem).Interface()
+	query, queryOpt := db.readQueryBuilder(model, opts...)
+	pageable := queryOpt.CursorPageable
+	if pageable == nil || pageable.Size <= 0 || len(queryOpt.Sort) > 0 || queryOpt.Pageable != nil || queryOpt.Offset != 0 {
+		return nil, WithStack(ErrorCursorPageable)
+	}
+	s, err := parseSchema(query, model)
+	if err != nil {
+		return nil, WithStack(err)
+	}
+	fields, err := cursorSortFields(s, pageable.Sort)
+	if err != nil {
+		return nil, err
+	}
+	table := ""
+	if queryOpt.Table == "" {
+		table = s.Table
+	}
+
+	var prev bool
+	if pageable.Cursor != "" {
+		c, err := decodeCursor(pageable.Cursor, pageable.Sort, fields)
+		if err != nil {
+			return nil, err
+		}
+		values := make([]interface{}, 0, len(fields))
+		for i, field := range fields {
+			value := reflect.New(field.Field.FieldType)
+			if err := json.Unmarshal(c.Values[i], value.Interface()); err != nil {
+				return nil, WithStack(ErrorCursorInvalid)
+			}
+			values = append(values, value.Elem().Interface())
+		}
+		prev = c.Prev
+		condition, args := keysetCondition(query, table, fields, values, prev)
+		query = query.Where(condition, args...)
+	}
+
+	// backwards pages are read in reverse order and flipped afterwards
+	orders := make([]*SortField, 0, len(fields))
+	for _, field := range fields {
+		orders = append(orders, &SortField{Field: field.Field, Desc: field.Desc != prev})
+	}
+	for _, order := range sortOrders(query, table, orders) {
+		query = query.Order(order)
+	}
+	if err := query.Limit(pageable.Size + 1).Find(list).Error; err != nil {
+		return nil, WithStack(err)
+	}
+
+	listV := reflect.ValueOf(list).Elem()
+	more := listV.Len() > pageable.Size
+	if more {
+		listV.Set(listV.Slice(0, pageable.Size))
+	}
+	if prev {
+		swap := reflect.Swapper(listV.Interface())
+		for i, j := 0, listV.Len()-1; i < j; i, j = i+1, j-1 {
+			swap(i, j)
+		}
+	}
+
+	cursors := &Cursors{}
+	if listV.Len() == 0 {
+		return cursors, nil
+	}
+	ctx := query.Statement.Context
+	if more || prev {
+		if cursors.Next, err = cursorOf(ctx, listV.Index(listV.Len()-1), pageable.Sort, fields, false); err != nil {
+			return nil, err
+		}
+	}
+	if (more && prev) || (!prev && pageable.Cursor != "") {
+		if cursors.Prev, err = cursorOf(ctx, listV.Index(0), pageable.Sort, fields, true); err != nil {
+			return nil, err
+		}
+	}
+	return cursors, nil
+}
@@ -0,0 +1,190 @@
+package mysql
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// captureFind records the SQL of queries run by db and answers them with the
+// rows rows points to at the time.
+func captureFind(t *testing.T, db *DB, rows *[]*testOrder) (*string, *[]interface{}) {
+	t.Helper()
+	var sql string
+	var vars []interface{}
+	err := db.Callback().Query().After("gorm:query").Register("test:capture", func(g *gorm.DB) {
+		sql = g.Statement.SQL.String()
+		vars = g.Statement.Vars
+		if list, ok := g.Statement.Dest.(*[]*testOrder); ok {
+			*list = append((*list)[:0], *rows...)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &sql, &vars
+}
+
+func TestKeysetCondition(t *testing.T) {
+	db := newDryRunDB(t)
+	s, err := parseSchema(db.DB, &testOrder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields, err := cursorSortFields(s, "amount,desc;name,asc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the tiebreak follows the direction of the last field
+	if len(fields) != 3 || fields[2].Field != s.PrioritizedPrimaryField || fields[2].Desc {
+		t.Fatalf("want ascending id tiebreak appended, got %d fields", len(fields))
+	}
+
+	cases := []struct {
+		name string
+		prev bool
+		sql  string
+	}{
+		{
+			name: "next",
+			sql: "((`test_orders`.`amount` < ?) OR (`test_orders`.`amount` = ? AND `test_orders`.`name` > ?) OR " +
+				"(`test_orders`.`amount` = ? AND `test_orders`.`name` = ? AND `test_orders`.`id` > ?))",
+		},
+		{
+			name: "prev",
+			prev: true,
+			sql: "((`test_orders`.`amount` > ?) OR (`test_orders`.`amount` = ? AND `test_orders`.`name` < ?) OR " +
+				"(`test_orders`.`amount` = ? AND `test_orders`.`name` = ? AND `test_orders`.`id` < ?))",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			sql, args := keysetCondition(db.DB, "test_orders", fields, []interface{}{5, "b", int64(7)}, c.prev)
+			if sql != c.sql {
+				t.Errorf("sql = %s\nwant  %s", sql, c.sql)
+			}
+			want := []interface{}{5, 5, "b", 5, "b", int64(7)}
+			if !reflect.DeepEqual(args, want) {
+				t.Errorf("args = %#v, want %#v", args, want)
+			}
+		})
+	}
+}
+
+func TestCursorSortFieldsKeepsExplicitPrimaryKey(t *testing.T) {
+	s, err := parseSchema(newDryRunDB(t).DB, &testOrder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields, err := cursorSortFields(s, "id,asc;name,desc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 2 {
+		t.Fatalf("len(fields) = %d, want 2", len(fields))
+	}
+	if _, err := cursorSortFields(s, "name,asc,nullsLast"); !errors.Is(err, ErrorCursorSortNulls) {
+		t.Fatalf("err = %v, want %v", err, ErrorCursorSortNulls)
+	}
+}
+
+func TestFindCursorPageRoundTrip(t *testing.T) {
+	db := newDryRunDB(t)
+	rows := []*testOrder{
+		{Id: 9, Name: "a", Amount: 30},
+		{Id: 8, Name: "b", Amount: 20},
+		{Id: 7, Name: "c", Amount: 20},
+	}
+	sql, vars := captureFind(t, db, &rows)
+	const sort = "amount,desc;name,asc"
+
+	// first page: two rows plus one more
+	list := []*testOrder{}
+	first, err := db.FindCursorPage(&list, WithCursorPage("", 2, sort))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "SELECT * FROM `test_orders` ORDER BY `test_orders`.`amount` DESC,`test_orders`.`name`,`test_orders`.`id` LIMIT ?"
+	if *sql != want {
+		t.Errorf("sql = %s\nwant  %s", *sql, want)
+	}
+	if len(list) != 2 || list[1].Id != 8 {
+		t.Fatalf("list = %v, want the first two rows", list)
+	}
+	if first.Next == "" || first.Prev != "" {
+		t.Fatalf("cursors = %+v, want only next", first)
+	}
+
+	// next page continues after the last row of the first page
+	rows = rows[2:]
+	list = []*testOrder{}
+	second, err := db.FindCursorPage(&list, WithCursorPage(first.Next, 2, sort))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "SELECT * FROM `test_orders` WHERE ((`test_orders`.`amount` < ?) OR " +
+		"(`test_orders`.`amount` = ? AND `test_orders`.`name` > ?) OR " +
+		"(`test_orders`.`amount` = ? AND `test_orders`.`name` = ? AND `test_orders`.`id` > ?)) " +
+		"ORDER BY `test_orders`.`amount` DESC,`test_orders`.`name`,`test_orders`.`id` LIMIT ?"
+	if *sql != want {
+		t.Errorf("sql = %s\nwant  %s", *sql, want)
+	}
+	wantVars := []interface{}{20, 20, "b", 20, "b", int64(8), 3}
+	if !reflect.DeepEqual(*vars, wantVars) {
+		t.Errorf("vars = %#v, want %#v", *vars, wantVars)
+	}
+	if second.Next != "" || second.Prev == "" {
+		t.Fatalf("cursors = %+v, want only prev", second)
+	}
+
+	// prev page reads backwards from the first row of the second page
+	list = []*testOrder{}
+	if _, err := db.FindCursorPage(&list, WithCursorPage(second.Prev, 2, sort)); err != nil {
+		t.Fatal(err)
+	}
+	want = "SELECT * FROM `test_orders` WHERE ((`test_orders`.`amount` > ?) OR " +
+		"(`test_orders`.`amount` = ? AND `test_orders`.`name` < ?) OR " +
+		"(`test_orders`.`amount` = ? AND `test_orders`.`name` = ? AND `test_orders`.`id` < ?)) " +
+		"ORDER BY `test_orders`.`amount`,`test_orders`.`name` DESC,`test_orders`.`id` DESC LIMIT ?"
+	if *sql != want {
+		t.Errorf("sql = %s\nwant  %s", *sql, want)
+	}
+	wantVars = []interface{}{20, 20, "c", 20, "c", int64(7), 3}
+	if !reflect.DeepEqual(*vars, wantVars) {
+		t.Errorf("vars = %#v, want %#v", *vars, wantVars)
+	}
+}
+
+func TestFindCursorPageRejects(t *testing.T) {
+	cases := []struct {
+		name string
+		opts []Option
+		err  error
+	}{
+		{name: "no cursor pageable", opts: []Option{WithPage(1, 10, "")}, err: ErrorCursorPageable},
+		{name: "with pageable", opts: []Option{WithCursorPage("", 10, "name,asc"), WithPage(2, 10, "")}, err: ErrorCursorPageable},
+		{name: "with sort", opts: []Option{WithCursorPage("", 10, ""), WithSort("name")}, err: ErrorCursorPageable},
+		{name: "with offset", opts: []Option{WithCursorPage("", 10, ""), WithOffset(10)}, err: ErrorCursorPageable},
+		{name: "sort changed", opts: []Option{WithCursorPage(mustCursor(t, "name,asc"), 10, "name,desc")}, err: ErrorCursorInvalid},
+		{name: "garbage", opts: []Option{WithCursorPage("%%", 10, "")}, err: ErrorCursorInvalid},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := newDryRunDB(t).FindCursorPage(&[]*testOrder{}, c.opts...)
+			if !errors.Is(err, c.err) {
+				t.Fatalf("err = %v, want %v", err, c.err)
+			}
+		})
+	}
+}
+
+func mustCursor(t *testing.T, sort string) string {
+	t.Helper()
+	value, err := encodeCursor(&cursor{Sort: sort, Values: nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
@@ -0,0 +1,57 @@
+package mysql
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// deferJoin reports whether the page of query is fetched by a deferred join,
+// per WithDeferredJoin or Config.DeferredJoinOffset.
+func (db *DB) deferJoin(query *gorm.DB, queryOption *QueryOption) bool {
+	minOffset := 0
+	if queryOption.DeferredJoin != nil {
+		minOffset = *queryOption.DeferredJoin
+	} else if db.config != nil && db.config.DeferredJoinOffset > 0 {
+		minOffset = db.config.DeferredJoinOffset
+	} else {
+		return false
+	}
+	if queryOption.Table != "" {
+		return false
+	}
+	limit, ok := query.Statement.Clauses["LIMIT"].Expression.(clause.Limit)
+	if !ok || limit.Limit == nil || *limit.Limit < 0 || limit.Offset < minOffset {
+		return false
+	}
+	return !groupedOrDistinct(query, queryOption)
+}
+
+// deferredJoin rewrites query to select the primary keys of the page with the
+// conditions, sort, limit and offset first and join the rows back to them, so
+// a deep offset skips over index entries instead of whole rows.
+func deferredJoin(model interface{}, query *gorm.DB, queryOption *QueryOption) *gorm.DB {
+	s, err := parseSchema(query, model)
+	if err != nil {
+		return query
+	}
+	pk := getPKName(query.Config, model)
+	if pk == "" {
+		return query
+	}
+	column := query.Statement.Quote(clause.Column{Table: s.Table, Name: pk})
+
+	keys := query.Session(&gorm.Session{}).Select(column + " AS deferred_pk")
+	if len(queryOption.SelectJoin) > 0 {
+		joins := keys.Statement.Joins[:0:0]
+		for _, join := range keys.Statement.Joins {
+			if !isSelectJoin(queryOption, join.Name) {
+				joins = append(joins, join)
+			}
+		}
+		keys.Statement.Joins = joins
+	}
+
+	rows := query.Session(&gorm.Session{}).Limit(-1).Offset(-1)
+	delete(rows.Statement.Clauses, "WHERE")
+	return rows.Joins("INNER JOIN (?) AS deferred_page ON "+column+" = deferred_page.deferred_pk", keys)
+}
@@ -0,0 +1,69 @@
+package mysql
+
+import "testing"
+
+func TestDeferredJoin(t *testing.T) {
+	const columns = "SELECT `test_orders`.`id`,`test_orders`.`name`,`test_orders`.`code`,`test_orders`.`phone`,`test_orders`.`amount`,`test_orders`.`secret`,`test_orders`.`password` FROM `test_orders` "
+	cases := []struct {
+		name   string
+		config *Config
+		opts   []Option
+		sql    string
+	}{
+		{
+			name: "below threshold",
+			opts: []Option{WithPage(11, 10, ""), WithDeferredJoin(500)},
+			sql:  "SELECT * FROM `test_orders` ORDER BY id desc LIMIT ? OFFSET ?",
+		},
+		{
+			name: "above threshold",
+			opts: []Option{WithPage(11, 10, ""), WithDeferredJoin(100), WithFilters(map[string]interface{}{"name": "a"})},
+			sql:  columns + "INNER JOIN (SELECT `test_orders`.`id` AS deferred_pk FROM `test_orders` WHERE `test_orders`.`name` = ? ORDER BY id desc LIMIT ? OFFSET ?) AS deferred_page ON `test_orders`.`id` = deferred_page.deferred_pk ORDER BY id desc ",
+		},
+		{
+			name:   "config threshold",
+			config: &Config{DeferredJoinOffset: 100},
+			opts:   []Option{WithPage(11, 10, "")},
+			sql:    columns + "INNER JOIN (SELECT `test_orders`.`id` AS deferred_pk FROM `test_orders` ORDER BY id desc LIMIT ? OFFSET ?) AS deferred_page ON `test_orders`.`id` = deferred_page.deferred_pk ORDER BY id desc ",
+		},
+		{
+			name:   "below config threshold",
+			config: &Config{DeferredJoinOffset: 500},
+			opts:   []Option{WithPage(11, 10, "")},
+			sql:    "SELECT * FROM `test_orders` ORDER BY id desc LIMIT ? OFFSET ?",
+		},
+		{
+			name: "select join kept out of keys",
+			opts: []Option{
+				WithPage(11, 10, ""), WithDeferredJoin(0),
+				WithSelectJoin("LEFT JOIN test_users ON test_users.id = test_orders.id"),
+			},
+			sql: columns + "LEFT JOIN test_users ON test_users.id = test_orders.id INNER JOIN (SELECT `test_orders`.`id` AS deferred_pk FROM `test_orders` ORDER BY `test_orders`.`id` desc LIMIT ? OFFSET ?) AS deferred_page ON `test_orders`.`id` = deferred_page.deferred_pk ORDER BY `test_orders`.`id` desc ",
+		},
+		{
+			name: "grouped",
+			opts: []Option{WithPage(11, 10, ""), WithDeferredJoin(0), WithSelect("name"), WithGroup("name")},
+			sql:  "SELECT `name` FROM `test_orders` GROUP BY `name` ORDER BY id desc LIMIT ? OFFSET ?",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			db := newDryRunDB(t)
+			if c.config != nil {
+				db.config = c.config
+			}
+			query, queryOpt := db.readQueryBuilder(&testOrder{}, c.opts...)
+			query = defaultSort(&testOrder{}, query, queryOpt)
+			if db.deferJoin(query, queryOpt) {
+				query = deferredJoin(&testOrder{}, query, queryOpt)
+			}
+			result := query.Find(&[]*testOrder{})
+			if result.Error != nil {
+				t.Fatal(result.Error)
+			}
+			if sql := result.Statement.SQL.String(); sql != c.sql {
+				t.Errorf("sql = %s\nwant  %s", sql, c.sql)
+			}
+		})
+	}
+}
@@ -7,12 +7,15 @@ import (
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
 	"io"
-	"reflect"
 	"runtime"
 	"strings"
 )
 
 var (
+	ErrorConfigUnset               = stderrors.New("config unset")
+	ErrorUrlUnset                  = stderrors.New("url unset")
+	ErrorLoggerUnset               = stderrors.New("logger unset")
+	ErrorTLSCA                     = stderrors.New("tls ca file has no certificate")
 	ErrorModel                     = stderrors.New("model is not ptr or mismatch")
 	ErrorPrimaryKeyUnset           = stderrors.New("model primary key is undefined")
 	ErrorPrimaryKeyInvalid         = stderrors.New("model primary key is invalid")
@@ -27,6 +30,19 @@ var (
 	ErrorUniqueIndexUnset          = stderrors.New("unique index unset")
 	ErrorUniqueIndexTypeMismatch   = stderrors.New("unique index type mismatch")
 	ErrorUniqueIndexNameEmpty      = stderrors.New("unique index name empty")
+	ErrorSortField                 = stderrors.New("sort field not allowed")
+	ErrorSortDirection             = stderrors.New("sort direction invalid")
+	ErrorFilterColumn              = stderrors.New("filter column not allowed")
+	ErrorFilterValue               = stderrors.New("filter value invalid for operator")
+	ErrorFilterGroup               = stderrors.New("filter group must be a filters map or a list of them")
+	ErrorFilterDepth               = stderrors.New("filter groups nested too deep")
+	ErrorFilterOperator            = stderrors.New("filter operator not allowed")
+	ErrorCursorPageable            = stderrors.New("cursor pageable not supplied or combined with sort, page or offset")
+	ErrorCursorInvalid             = stderrors.New("cursor invalid")
+	ErrorCursorSortNulls           = stderrors.New("cursor sort can't use nulls order")
+	ErrorAggregate                 = stderrors.New("aggregate invalid")
+	ErrorTransactionUnset          = stderrors.New("not in a managed transaction")
+	ErrorTransactionDone           = stderrors.New("transaction already finished")
 )
 
 func (db *DB) IsUniqueIndexError(err error) bool {
@@ -46,12 +62,29 @@ func (db *DB) IsRecordNotAffectedError(err error) bool {
 }
 
 func IsUniqueIndexError(err error) bool {
-	cause := errors.Cause(err)
-	errType := reflect.TypeOf(cause).String()
-	if errType == "*mysql.MySQLError" && cause.(*mysql.MySQLError).Number == 1062 {
-		return true
+	return mysqlErrorNumber(err) == 1062
+}
+
+func IsDeadlockError(err error) bool {
+	return mysqlErrorNumber(err) == 1213
+}
+
+func IsLockWaitTimeoutError(err error) bool {
+	return mysqlErrorNumber(err) == 1205
+}
+
+// IsRetryableError reports whether re-running the whole transaction may
+// succeed, i.e. it was aborted by a deadlock or a lock wait timeout.
+func IsRetryableError(err error) bool {
+	return IsDeadlockError(err) || IsLockWaitTimeoutError(err)
+}
+
+func mysqlErrorNumber(err error) uint16 {
+	var e *mysql.MySQLError
+	if stderrors.As(err, &e) {
+		return e.Number
 	}
-	return false
+	return 0
 }
 
 func IsNotSingleError(err error) bool {
@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"github.com/go-estar/types/fieldUtil"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
 	"reflect"
+	"sort"
 	"strings"
 )
 
@@ -17,8 +20,16 @@ const (
 	SymbolLessThan          = "lt"
 	SymbolLike              = "like"
 	SymbolNotLike           = "notLike"
+	SymbolLikeRaw           = "likeRaw"
 	SymbolIn                = "in"
 	SymbolNotIn             = "notIn"
+	SymbolBetween           = "between"
+	SymbolIsNull            = "isNull"
+	SymbolNotNull           = "notNull"
+	SymbolStartsWith        = "startsWith"
+	SymbolEndsWith          = "endsWith"
+	SymbolRegexp            = "regexp"
+	SymbolILike             = "ilike"
 	SymbolFunc              = "func"
 )
 
@@ -31,14 +42,37 @@ var Symbol = map[string]string{
 	SymbolLessThan:          "<",
 	SymbolLike:              "like",
 	SymbolNotLike:           "not like",
+	SymbolLikeRaw:           "like",
 	SymbolIn:                "in",
 	SymbolNotIn:             "not in",
+	SymbolBetween:           "between",
+	SymbolIsNull:            "is null",
+	SymbolNotNull:           "is not null",
+	SymbolStartsWith:        "like",
+	SymbolEndsWith:          "like",
+	SymbolRegexp:            "regexp",
+	SymbolILike:             "like",
 }
 
+// FilterILikeCollation is the case-insensitive collation SymbolILike compares with.
+var FilterILikeCollation = "utf8mb4_general_ci"
+
+const (
+	FilterGroupAnd = "$and"
+	FilterGroupOr  = "$or"
+	FilterGroupNot = "$not"
+)
+
+// FilterMaxDepth bounds how deep filter groups may nest.
+var FilterMaxDepth = 4
+
 type FilterKey struct {
 	Column          string
 	Operator        string
 	IgnoreZeroValue bool // Prefix"?"
+	// Columns splits Column by "|", a filter on several columns matches when
+	// any of them does.
+	Columns []string
 }
 
 func keyFormat(key string) *FilterKey {
@@ -61,53 +95,427 @@ func keyFormat(key string) *FilterKey {
 		filterKey.Column = key[:oIndex]
 		filterKey.Operator = key[oIndex+1:]
 	}
+	filterKey.Columns = strings.Split(filterKey.Column, "|")
 	return &filterKey
 }
 
+type FilterError struct {
+	Key string
+	Err error
+}
+
+func (e *FilterError) Error() string {
+	return fmt.Sprintf("filter %q: %v", e.Key, e.Err)
+}
+
+func (e *FilterError) Unwrap() error {
+	return e.Err
+}
+
+// Filters applies filters without validating the columns, use it for filters
+// built by code only.
 func Filters(query *gorm.DB, filters map[string]interface{}) *gorm.DB {
-	for key, val := range filters {
+	query, err := (&filterBuilder{}).apply(query, filters)
+	if err != nil {
+		query.AddError(err)
+	}
+	return query
+}
+
+// filters applies the filters of queryOption, restricted to the columns of
+// model unless WithIgnoreFilterWhitelist is set.
+func (db *DB) filters(query *gorm.DB, model interface{}, queryOption *QueryOption) *gorm.DB {
+	if queryOption.IgnoreFilterWhitelist {
+		return Filters(query, queryOption.Filters)
+	}
+	s, err := parseSchema(query, model)
+	if err != nil {
+		query.AddError(WithStack(&FilterError{Err: err}))
+		return query
+	}
+	builder := &filterBuilder{schema: s}
+	if queryOption.Table == "" {
+		builder.table = s.Table
+	}
+	for _, join := range queryOption.Join {
+		if name, ok := join[0].(string); ok {
+			builder.existing = append(builder.existing, name)
+		}
+	}
+	query, err = builder.apply(query, queryOption.Filters)
+	if err != nil {
+		query.AddError(err)
+	}
+	return query
+}
+
+type filterBuilder struct {
+	// schema restricts the columns, nil accepts any key
+	schema *schema.Schema
+	table  string
+	// existing are the joins of WithJoin, relation filters reuse the
+	// association joins among them
+	existing []string
+	// joins are added by relation filters, keyed by alias
+	joins  []filterJoin
+	joined map[string]string
+}
+
+func (b *filterBuilder) apply(query *gorm.DB, filters map[string]interface{}) (*gorm.DB, error) {
+	query, err := b.where(query, filters, 0)
+	if err != nil {
+		return query, err
+	}
+	for _, join := range b.joins {
+		query = query.Joins(join.sql, join.args...)
+	}
+	return query, nil
+}
+
+func (b *filterBuilder) where(query *gorm.DB, filters map[string]interface{}, depth int) (*gorm.DB, error) {
+	keys := make([]string, 0, len(filters))
+	for key := range filters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		val := filters[key]
 		if val == nil {
 			continue
 		}
 
+		if op, ok := groupOperator(key); ok {
+			group, err := b.group(query, key, op, val, depth+1)
+			if err != nil {
+				return query, err
+			}
+			if group != nil {
+				query = query.Where(group)
+			}
+			continue
+		}
+
 		filterKey := keyFormat(key)
 		if filterKey.IgnoreZeroValue && fieldUtil.IsEmpty(val) {
 			continue
 		}
-
-		switch filterKey.Operator {
-		case SymbolEquals:
-			query = query.Where(filterKey.Column + " = ?", val)
-		case SymbolNotEquals:
-			query = query.Where(filterKey.Column + " != ?", val)
-		case SymbolGreatThanOrEquals:
-			query = query.Where(filterKey.Column + " >= ?", val)
-		case SymbolGreatThan:
-			query = query.Where(filterKey.Column + " > ?", val)
-		case SymbolLessThanOrEquals:
-			query = query.Where(filterKey.Column + " <= ?", val)
-		case SymbolLessThan:
-			query = query.Where(filterKey.Column + " < ?", val)
-		case SymbolLike:
-			query = query.Where(filterKey.Column + " like ?", fmt.Sprintf("%%%s%%", val))
-		case SymbolNotLike:
-			query = query.Where(filterKey.Column + " not like ?", fmt.Sprintf("%%%s%%", val))
-		case SymbolIn:
-			query = query.Where(filterKey.Column + " in (?)", val)
-		case SymbolNotIn:
-			query = query.Where(filterKey.Column + " not in (?)", val)
-		case SymbolFunc:
+		if filterKey.Operator == SymbolFunc {
 			fn, ok := val.(func(db2 *gorm.DB))
 			if ok {
+				// fn adds to query in place, inside a group query may still be
+				// the new session that the first chained call would clone
+				query = query.Clauses()
 				fn(query)
 			}
-		default:
-			if reflect.ValueOf(val).Kind() == reflect.Slice {
-				query = query.Where(filterKey.Column + " in (?)", val)
-			} else {
-				query = query.Where(filterKey.Column + " = ?", val)
+			continue
+		}
+
+		if len(filterKey.Columns) > 1 {
+			group, err := b.anyColumn(query, key, filterKey, val)
+			if err != nil {
+				return query, err
 			}
+			query = query.Where(group)
+			continue
+		}
+
+		column, err := b.column(query, key, filterKey)
+		if err != nil {
+			return query, err
+		}
+		if query, err = b.condition(query, key, filterKey, column, val); err != nil {
+			return query, err
 		}
 	}
-	return query
+	return query, nil
+}
+
+// condition adds the condition of filterKey on column to query.
+func (b *filterBuilder) condition(query *gorm.DB, key string, filterKey *FilterKey, column string, val interface{}) (*gorm.DB, error) {
+	switch filterKey.Operator {
+	case SymbolEquals:
+		query = query.Where(column+" = ?", val)
+	case SymbolNotEquals:
+		query = query.Where(column+" != ?", val)
+	case SymbolGreatThanOrEquals:
+		query = query.Where(column+" >= ?", val)
+	case SymbolGreatThan:
+		query = query.Where(column+" > ?", val)
+	case SymbolLessThanOrEquals:
+		query = query.Where(column+" <= ?", val)
+	case SymbolLessThan:
+		query = query.Where(column+" < ?", val)
+	case SymbolLike:
+		query = query.Where(column+" like ?"+likeEscape, "%"+escapeLike(fmt.Sprint(val))+"%")
+	case SymbolNotLike:
+		query = query.Where(column+" not like ?"+likeEscape, "%"+escapeLike(fmt.Sprint(val))+"%")
+	case SymbolIn:
+		query = query.Where(column+" in (?)", val)
+	case SymbolNotIn:
+		query = query.Where(column+" not in (?)", val)
+	case SymbolBetween:
+		from, to, ok := filterRange(val)
+		if !ok {
+			return query, WithStack(&FilterError{Key: key, Err: ErrorFilterValue})
+		}
+		switch {
+		case from != nil && to != nil:
+			query = query.Where(column+" between ? and ?", from, to)
+		case from != nil:
+			query = query.Where(column+" >= ?", from)
+		case to != nil:
+			query = query.Where(column+" <= ?", to)
+		}
+	case SymbolIsNull, SymbolNotNull:
+		isNull, ok := val.(bool)
+		if !ok {
+			return query, WithStack(&FilterError{Key: key, Err: ErrorFilterValue})
+		}
+		if isNull == (filterKey.Operator == SymbolIsNull) {
+			query = query.Where(column + " is null")
+		} else {
+			query = query.Where(column + " is not null")
+		}
+	case SymbolStartsWith, SymbolEndsWith, SymbolRegexp, SymbolILike, SymbolLikeRaw:
+		str, ok := val.(string)
+		if !ok {
+			return query, WithStack(&FilterError{Key: key, Err: ErrorFilterValue})
+		}
+		switch filterKey.Operator {
+		case SymbolStartsWith:
+			query = query.Where(column+" like ?"+likeEscape, escapeLike(str)+"%")
+		case SymbolEndsWith:
+			query = query.Where(column+" like ?"+likeEscape, "%"+escapeLike(str))
+		case SymbolRegexp:
+			query = query.Where(column+" regexp ?", str)
+		case SymbolILike:
+			query = query.Where(column+" collate "+FilterILikeCollation+" like ?"+likeEscape, "%"+escapeLike(str)+"%")
+		case SymbolLikeRaw:
+			query = query.Where(column+" like ?", str)
+		}
+	default:
+		if filterKey.Operator != "" && b.schema != nil {
+			return query, WithStack(&FilterError{Key: key, Err: ErrorFilterOperator})
+		}
+		if reflect.ValueOf(val).Kind() == reflect.Slice {
+			query = query.Where(column+" in (?)", val)
+		} else {
+			query = query.Where(column+" = ?", val)
+		}
+	}
+	return query, nil
+}
+
+// anyColumn compiles a filter on several columns, e.g. "name|code$like", into
+// one group matching when any of them does.
+func (b *filterBuilder) anyColumn(query *gorm.DB, key string, filterKey *FilterKey, val interface{}) (clause.Expression, error) {
+	group := filterGroup{op: "OR"}
+	for _, name := range filterKey.Columns {
+		columnKey := &FilterKey{Column: name, Columns: []string{name}, Operator: filterKey.Operator}
+		column, err := b.column(query, key, columnKey)
+		if err != nil {
+			return nil, err
+		}
+		sub, err := b.condition(query.Session(&gorm.Session{NewDB: true}), key, columnKey, column, val)
+		if err != nil {
+			return nil, err
+		}
+		if where, ok := sub.Statement.Clauses["WHERE"].Expression.(clause.Where); ok {
+			group.branches = append(group.branches, where.Exprs)
+		}
+	}
+	return group, nil
+}
+
+// likeEscape is appended to like conditions whose value went through
+// escapeLike, "!" avoids depending on NO_BACKSLASH_ESCAPES.
+const likeEscape = " escape '!'"
+
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// escapeLike makes the wildcards in s match literally, SymbolLikeRaw passes
+// patterns through as is.
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// filterRange reads the bounds of a between filter, a two-element list or a
+// {"from": ..., "to": ...} object with at least one bound. A nil bound is open.
+func filterRange(val interface{}) (interface{}, interface{}, bool) {
+	if m, ok := val.(map[string]interface{}); ok {
+		for key := range m {
+			if key != "from" && key != "to" {
+				return nil, nil, false
+			}
+		}
+		from, to := m["from"], m["to"]
+		return from, to, from != nil || to != nil
+	}
+	v := reflect.ValueOf(val)
+	if (v.Kind() != reflect.Slice && v.Kind() != reflect.Array) || v.Len() != 2 {
+		return nil, nil, false
+	}
+	from, to := v.Index(0).Interface(), v.Index(1).Interface()
+	return from, to, from != nil || to != nil
+}
+
+// groupOperator returns the operator of a $and, $or or $not key, which may
+// carry a "#" suffix like any other key.
+func groupOperator(key string) (string, bool) {
+	if i := strings.Index(key, "#"); i != -1 {
+		key = key[:i]
+	}
+	switch key {
+	case FilterGroupAnd:
+		return "AND", true
+	case FilterGroupOr:
+		return "OR", true
+	case FilterGroupNot:
+		return "NOT", true
+	}
+	return "", false
+}
+
+// group compiles the branches of a filter group, each a filters map, into one
+// condition. It returns nil when every branch is empty.
+func (b *filterBuilder) group(query *gorm.DB, key string, op string, val interface{}, depth int) (clause.Expression, error) {
+	if depth > FilterMaxDepth {
+		return nil, WithStack(&FilterError{Key: key, Err: ErrorFilterDepth})
+	}
+	branches, ok := filterBranches(val)
+	if !ok || (op == "NOT" && len(branches) != 1) {
+		return nil, WithStack(&FilterError{Key: key, Err: ErrorFilterGroup})
+	}
+	group := filterGroup{op: op}
+	for _, branch := range branches {
+		sub, err := b.where(query.Session(&gorm.Session{NewDB: true}), branch, depth)
+		if err != nil {
+			return nil, err
+		}
+		if sub.Error != nil {
+			return nil, sub.Error
+		}
+		if where, ok := sub.Statement.Clauses["WHERE"].Expression.(clause.Where); ok && len(where.Exprs) > 0 {
+			group.branches = append(group.branches, where.Exprs)
+		}
+	}
+	if len(group.branches) == 0 {
+		return nil, nil
+	}
+	return group, nil
+}
+
+// filterBranches accepts a filters map or a list of them, as decoded from JSON
+// or built by code.
+func filterBranches(val interface{}) ([]map[string]interface{}, bool) {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		return []map[string]interface{}{v}, true
+	case []map[string]interface{}:
+		return v, true
+	case []interface{}:
+		branches := make([]map[string]interface{}, 0, len(v))
+		for _, item := range v {
+			branch, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			branches = append(branches, branch)
+		}
+		return branches, true
+	}
+	return nil, false
+}
+
+// filterGroup joins the conditions of a branch with AND and the branches with
+// op, parenthesizing each level. A NOT group has a single branch.
+type filterGroup struct {
+	op       string
+	branches [][]clause.Expression
+}
+
+func (g filterGroup) Build(builder clause.Builder) {
+	if g.op == "NOT" {
+		builder.WriteString("NOT ")
+	}
+	builder.WriteByte('(')
+	for i, branch := range g.branches {
+		if i > 0 {
+			builder.WriteString(" " + g.op + " ")
+		}
+		if len(g.branches) > 1 && len(branch) > 1 {
+			builder.WriteByte('(')
+		}
+		for j, expr := range branch {
+			if j > 0 {
+				builder.WriteString(" AND ")
+			}
+			wrap := false
+			if e, ok := expr.(clause.Expr); ok && len(branch) > 1 {
+				sql := strings.ToUpper(e.SQL)
+				wrap = strings.Contains(sql, " AND ") || strings.Contains(sql, " OR ")
+			}
+			if wrap {
+				builder.WriteByte('(')
+			}
+			expr.Build(builder)
+			if wrap {
+				builder.WriteByte(')')
+			}
+		}
+		if len(g.branches) > 1 && len(branch) > 1 {
+			builder.WriteByte(')')
+		}
+	}
+	builder.WriteByte(')')
+}
+
+// column returns the quoted column of filterKey, validated against the schema.
+// A dotted column is resolved through the associations of the schema.
+func (b *filterBuilder) column(query *gorm.DB, key string, filterKey *FilterKey) (string, error) {
+	if b.schema == nil {
+		return filterKey.Column, nil
+	}
+	table, s, name := b.table, b.schema, filterKey.Column
+	if i := strings.LastIndex(name, "."); i != -1 && name[:i] != b.schema.Table {
+		var ok bool
+		if table, s, ok = b.relation(query, name[:i]); !ok {
+			return "", WithStack(&FilterError{Key: key, Err: ErrorFilterColumn})
+		}
+		name = name[i+1:]
+	}
+	field := lookupField(s, name)
+	if field == nil || !filterable(s, field, filterKey.Operator) {
+		return "", WithStack(&FilterError{Key: key, Err: ErrorFilterColumn})
+	}
+	return query.Statement.Quote(clause.Column{Table: table, Name: field.DBName}), nil
+}
+
+// filterable reports whether field may be filtered with operator. Fields
+// tagged `filter:"-"` never are, and once any column of s has a filter tag only
+// tagged fields are, tags on associations don't count. A tag may list the
+// allowed operators, e.g. `filter:"eq,in"`.
+func filterable(s *schema.Schema, field *schema.Field, operator string) bool {
+	tag, ok := field.Tag.Lookup("filter")
+	if !ok {
+		for _, f := range s.Fields {
+			if _, tagged := f.Tag.Lookup("filter"); tagged && f.DBName != "" {
+				return false
+			}
+		}
+		return true
+	}
+	if tag == "-" {
+		return false
+	}
+	if tag == "" || operator == "" {
+		return true
+	}
+	for _, allowed := range strings.Split(tag, ",") {
+		if strings.TrimSpace(allowed) == operator {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,97 @@
+package mysql
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+	"strings"
+)
+
+type filterJoin struct {
+	sql  string
+	args []interface{}
+}
+
+// relation resolves a dotted path of belongs-to and has-one associations,
+// e.g. "user.company", joining each once. It returns the alias and schema of
+// the last association.
+func (b *filterBuilder) relation(query *gorm.DB, path string) (string, *schema.Schema, bool) {
+	if b.table == "" {
+		return "", nil, false
+	}
+	table, s := b.table, b.schema
+	names := make([]string, 0, 2)
+	for _, name := range strings.Split(path, ".") {
+		rel := lookupRelation(s, name)
+		if rel == nil || (rel.Type != schema.BelongsTo && rel.Type != schema.HasOne) {
+			return "", nil, false
+		}
+		names = append(names, rel.Name)
+		table = b.join(query, table, strings.Join(names, "."), rel)
+		s = rel.FieldSchema
+	}
+	return table, s, true
+}
+
+// join left joins rel onto parent unless the association is joined already,
+// by a filter or a WithJoin of its name. A raw WithJoin of the same table is
+// not reused, its condition may join another association. It returns the
+// alias of the joined table.
+func (b *filterBuilder) join(query *gorm.DB, parent string, path string, rel *schema.Relationship) string {
+	if alias, ok := b.joined[path]; ok {
+		return alias
+	}
+	if b.joined == nil {
+		b.joined = map[string]string{}
+	}
+	// gorm aliases association joins by their path, nested ones joined by "__"
+	alias := strings.ReplaceAll(path, ".", "__")
+	for _, name := range b.existing {
+		if name == path {
+			b.joined[path] = alias
+			return alias
+		}
+	}
+	b.joined[path] = alias
+
+	on := make([]string, 0, len(rel.References))
+	var args []interface{}
+	for _, ref := range rel.References {
+		switch {
+		case ref.OwnPrimaryKey:
+			on = append(on, query.Statement.Quote(clause.Column{Table: parent, Name: ref.PrimaryKey.DBName})+" = "+
+				query.Statement.Quote(clause.Column{Table: alias, Name: ref.ForeignKey.DBName}))
+		case ref.PrimaryValue == "":
+			on = append(on, query.Statement.Quote(clause.Column{Table: parent, Name: ref.ForeignKey.DBName})+" = "+
+				query.Statement.Quote(clause.Column{Table: alias, Name: ref.PrimaryKey.DBName}))
+		default:
+			on = append(on, query.Statement.Quote(clause.Column{Table: alias, Name: ref.ForeignKey.DBName})+" = ?")
+			args = append(args, ref.PrimaryValue)
+		}
+	}
+	b.joins = append(b.joins, filterJoin{
+		sql:  "LEFT JOIN " + query.Statement.Quote(clause.Table{Name: rel.FieldSchema.Table, Alias: alias}) + " ON " + strings.Join(on, " AND "),
+		args: args,
+	})
+	return alias
+}
+
+// lookupRelation finds the association of s named by its JSON or struct field
+// name. Associations tagged `filter:"-"`, or `json:"-"` without a filter tag,
+// are not filterable.
+func lookupRelation(s *schema.Schema, name string) *schema.Relationship {
+	if name == "" {
+		return nil
+	}
+	for _, rel := range s.Relationships.Relations {
+		tag, tagged := rel.Field.Tag.Lookup("filter")
+		jsonName := strings.Split(rel.Field.Tag.Get("json"), ",")[0]
+		if tag == "-" || (jsonName == "-" && !tagged) {
+			continue
+		}
+		if (jsonName != "" && jsonName != "-" && jsonName == name) || rel.Name == name {
+			return rel
+		}
+	}
+	return nil
+}
@@ -0,0 +1,508 @@
+package mysql
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+)
+
+type testOrder struct {
+	Id       int64  `gorm:"primary_key" json:"id"`
+	Name     string `json:"name"`
+	Code     string `json:"code"`
+	Phone    string `json:"phone"`
+	Amount   int    `json:"amount"`
+	Secret   string
+	Password string `json:"-"`
+}
+
+// newDryRunDB returns a DB that builds SQL without a server.
+func newDryRunDB(t *testing.T) *DB {
+	t.Helper()
+	g, err := gorm.Open(mysql.New(mysql.Config{
+		DSN:                       "test:test@tcp(127.0.0.1:3306)/test",
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{DryRun: true, DisableAutomaticPing: true, Logger: gormLogger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &DB{DB: g, config: &Config{}}
+}
+
+// dryRunFind returns the SQL and vars the list query of opts runs.
+func dryRunFind(t *testing.T, db *DB, list interface{}, opts ...Option) (string, []interface{}, error) {
+	t.Helper()
+	elem := reflect.TypeOf(list).Elem().Elem()
+	if elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	model := reflect.New(elem).Interface()
+	query, _ := db.readQueryBuilder(model, opts...)
+	result := query.Find(list)
+	return result.Statement.SQL.String(), result.Statement.Vars, result.Error
+}
+
+type filterCase struct {
+	name    string
+	filters map[string]interface{}
+	sql     string
+	vars    []interface{}
+	err     error
+}
+
+func runFilterCases(t *testing.T, cases []filterCase) {
+	t.Helper()
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			sql, vars, err := dryRunFind(t, newDryRunDB(t), &[]*testOrder{}, WithFilters(c.filters))
+			if c.err != nil {
+				if !errors.Is(err, c.err) {
+					t.Fatalf("err = %v, want %v", err, c.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sql != c.sql {
+				t.Errorf("sql = %s\nwant  %s", sql, c.sql)
+			}
+			if len(vars) != 0 || len(c.vars) != 0 {
+				if !reflect.DeepEqual(vars, c.vars) {
+					t.Errorf("vars = %#v, want %#v", vars, c.vars)
+				}
+			}
+		})
+	}
+}
+
+func TestFilterWhitelist(t *testing.T) {
+	runFilterCases(t, []filterCase{
+		{
+			name:    "json name",
+			filters: map[string]interface{}{"name": "a"},
+			sql:     "SELECT * FROM `test_orders` WHERE `test_orders`.`name` = ?",
+			vars:    []interface{}{"a"},
+		},
+		{
+			name:    "struct field and column",
+			filters: map[string]interface{}{"Code$ne": "a", "amount$gte": 1},
+			sql:     "SELECT * FROM `test_orders` WHERE `test_orders`.`code` != ? AND `test_orders`.`amount` >= ?",
+			vars:    []interface{}{"a", 1},
+		},
+		{
+			name:    "field without json tag",
+			filters: map[string]interface{}{"secret": "a"},
+			sql:     "SELECT * FROM `test_orders` WHERE `test_orders`.`secret` = ?",
+			vars:    []interface{}{"a"},
+		},
+		{name: "unknown column", filters: map[string]interface{}{"missing": 1}, err: ErrorFilterColumn},
+		{name: "raw expression", filters: map[string]interface{}{"1=1 or name": 1}, err: ErrorFilterColumn},
+		{name: "unknown operator", filters: map[string]interface{}{"name$sleep": 1}, err: ErrorFilterOperator},
+		{name: "empty column", filters: map[string]interface{}{"$like": "x"}, err: ErrorFilterColumn},
+		{name: "json dash key", filters: map[string]interface{}{"-": "x"}, err: ErrorFilterColumn},
+		{name: "json hidden by column", filters: map[string]interface{}{"password$startsWith": "a"}, err: ErrorFilterColumn},
+		{name: "json hidden by field", filters: map[string]interface{}{"Password": "a"}, err: ErrorFilterColumn},
+		{name: "other table", filters: map[string]interface{}{"users.name": "a"}, err: ErrorFilterColumn},
+	})
+}
+
+type testTaggedOrder struct {
+	Id       int64  `gorm:"primary_key" json:"id"`
+	Name     string `json:"name" filter:"eq,like"`
+	Status   int    `json:"status" filter:""`
+	Note     string `json:"note"`
+	Password string `json:"-" filter:"eq"`
+	Token    string `json:"token" filter:"-"`
+}
+
+func TestFilterTags(t *testing.T) {
+	cases := []struct {
+		name    string
+		filters map[string]interface{}
+		err     error
+	}{
+		{name: "allowed operator", filters: map[string]interface{}{"name$like": "a"}},
+		{name: "default operator", filters: map[string]interface{}{"name": "a"}},
+		{name: "any operator", filters: map[string]interface{}{"status$gt": 1}},
+		{name: "json hidden with filter tag", filters: map[string]interface{}{"password": "a"}},
+		{name: "operator not listed", filters: map[string]interface{}{"name$ne": "a"}, err: ErrorFilterColumn},
+		{name: "untagged", filters: map[string]interface{}{"note": "a"}, err: ErrorFilterColumn},
+		{name: "excluded", filters: map[string]interface{}{"token": "a"}, err: ErrorFilterColumn},
+		{name: "json hidden operator not listed", filters: map[string]interface{}{"password$startsWith": "a"}, err: ErrorFilterColumn},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, _, err := dryRunFind(t, newDryRunDB(t), &[]*testTaggedOrder{}, WithFilters(c.filters))
+			if c.err == nil && err != nil {
+				t.Fatal(err)
+			}
+			if c.err != nil && !errors.Is(err, c.err) {
+				t.Fatalf("err = %v, want %v", err, c.err)
+			}
+		})
+	}
+}
+
+func TestFilterGroups(t *testing.T) {
+	runFilterCases(t, []filterCase{
+		{
+			name: "or",
+			filters: map[string]interface{}{
+				"amount$gt": 1,
+				"$or":       []interface{}{map[string]interface{}{"name": "a"}, map[string]interface{}{"code": "b", "phone": "c"}},
+			},
+			sql: "SELECT * FROM `test_orders` WHERE (`test_orders`.`name` = ? OR (`test_orders`.`code` = ? AND `test_orders`.`phone` = ?)) " +
+				"AND `test_orders`.`amount` > ?",
+			vars: []interface{}{"a", "b", "c", 1},
+		},
+		{
+			name: "not",
+			filters: map[string]interface{}{
+				"$not": map[string]interface{}{"name": "a", "code": "b"},
+			},
+			sql:  "SELECT * FROM `test_orders` WHERE NOT (`test_orders`.`code` = ? AND `test_orders`.`name` = ?)",
+			vars: []interface{}{"b", "a"},
+		},
+		{
+			name: "nested with suffixes",
+			filters: map[string]interface{}{
+				"$or#1": []map[string]interface{}{
+					{"name": "a"},
+					{"$and": []interface{}{map[string]interface{}{"code": "b"}, map[string]interface{}{"$not": []interface{}{map[string]interface{}{"phone": "c"}}}}},
+				},
+				"$or#2": []map[string]interface{}{{"amount": 1}, {"amount": 2}},
+			},
+			sql: "SELECT * FROM `test_orders` WHERE (`test_orders`.`name` = ? OR (`test_orders`.`code` = ? AND NOT (`test_orders`.`phone` = ?))) " +
+				"AND (`test_orders`.`amount` = ? OR `test_orders`.`amount` = ?)",
+			vars: []interface{}{"a", "b", "c", 1, 2},
+		},
+		{
+			name: "func",
+			filters: map[string]interface{}{
+				"name":  "a",
+				"$func": func(db *gorm.DB) { db.Where("code = ?", "b") },
+			},
+			sql:  "SELECT * FROM `test_orders` WHERE code = ? AND `test_orders`.`name` = ?",
+			vars: []interface{}{"b", "a"},
+		},
+		{
+			name: "func inside groups",
+			filters: map[string]interface{}{
+				"$or": []interface{}{
+					map[string]interface{}{"$func": func(db *gorm.DB) { db.Where("code = ?", "b") }},
+					map[string]interface{}{"$not": map[string]interface{}{"$func": func(db *gorm.DB) { db.Where("phone = ?", "c") }}},
+				},
+			},
+			sql:  "SELECT * FROM `test_orders` WHERE (code = ? OR NOT (phone = ?))",
+			vars: []interface{}{"b", "c"},
+		},
+		{
+			name:    "empty branches",
+			filters: map[string]interface{}{"$or": []interface{}{map[string]interface{}{}, map[string]interface{}{"name": nil}}},
+			sql:     "SELECT * FROM `test_orders`",
+		},
+		{
+			name:    "whitelist applies inside groups",
+			filters: map[string]interface{}{"$or": []interface{}{map[string]interface{}{"password": "a"}}},
+			err:     ErrorFilterColumn,
+		},
+		{name: "not a map", filters: map[string]interface{}{"$or": []interface{}{"name"}}, err: ErrorFilterGroup},
+		{name: "not with two branches", filters: map[string]interface{}{"$not": []interface{}{map[string]interface{}{}, map[string]interface{}{}}}, err: ErrorFilterGroup},
+		{name: "too deep", filters: nestedGroup(FilterMaxDepth + 1), err: ErrorFilterDepth},
+	})
+}
+
+func TestFilterGroupDepthLimit(t *testing.T) {
+	_, _, err := dryRunFind(t, newDryRunDB(t), &[]*testOrder{}, WithFilters(nestedGroup(FilterMaxDepth)))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// nestedGroup nests depth $and groups around a condition.
+func nestedGroup(depth int) map[string]interface{} {
+	filters := map[string]interface{}{"name": "a"}
+	for i := 0; i < depth; i++ {
+		filters = map[string]interface{}{"$and": filters}
+	}
+	return filters
+}
+
+func TestFilterOperators(t *testing.T) {
+	runFilterCases(t, []filterCase{
+		{
+			name:    "between list",
+			filters: map[string]interface{}{"amount$between": []interface{}{1, 5}},
+			sql:     "SELECT * FROM `test_orders` WHERE `test_orders`.`amount` between ? and ?",
+			vars:    []interface{}{1, 5},
+		},
+		{
+			name:    "between typed list",
+			filters: map[string]interface{}{"amount$between": []int{1, 5}},
+			sql:     "SELECT * FROM `test_orders` WHERE `test_orders`.`amount` between ? and ?",
+			vars:    []interface{}{1, 5},
+		},
+		{
+			name:    "between range",
+			filters: map[string]interface{}{"amount$between": map[string]interface{}{"from": 1, "to": 5}},
+			sql:     "SELECT * FROM `test_orders` WHERE `test_orders`.`amount` between ? and ?",
+			vars:    []interface{}{1, 5},
+		},
+		{
+			name:    "between open end",
+			filters: map[string]interface{}{"amount$between": map[string]interface{}{"from": 1}},
+			sql:     "SELECT * FROM `test_orders` WHERE `test_orders`.`amount` >= ?",
+			vars:    []interface{}{1},
+		},
+		{
+			name:    "between open start",
+			filters: map[string]interface{}{"amount$between": []interface{}{nil, 5}},
+			sql:     "SELECT * FROM `test_orders` WHERE `test_orders`.`amount` <= ?",
+			vars:    []interface{}{5},
+		},
+		{
+			name:    "null checks",
+			filters: map[string]interface{}{"code$isNull": true, "name$notNull": true, "phone$isNull": false},
+			sql:     "SELECT * FROM `test_orders` WHERE `test_orders`.`code` is null AND `test_orders`.`name` is not null AND `test_orders`.`phone` is not null",
+		},
+		{
+			name:    "regexp",
+			filters: map[string]interface{}{"code$regexp": "^A[0-9]+$"},
+			sql:     "SELECT * FROM `test_orders` WHERE `test_orders`.`code` regexp ?",
+			vars:    []interface{}{"^A[0-9]+$"},
+		},
+		{name: "between one value", filters: map[string]interface{}{"amount$between": 1}, err: ErrorFilterValue},
+		{name: "between three values", filters: map[string]interface{}{"amount$between": []int{1, 2, 3}}, err: ErrorFilterValue},
+		{name: "between no bounds", filters: map[string]interface{}{"amount$between": []interface{}{nil, nil}}, err: ErrorFilterValue},
+		{name: "between unknown bound", filters: map[string]interface{}{"amount$between": map[string]interface{}{"from": 1, "max": 2}}, err: ErrorFilterValue},
+		{name: "isNull not bool", filters: map[string]interface{}{"code$isNull": "yes"}, err: ErrorFilterValue},
+		{name: "startsWith not string", filters: map[string]interface{}{"code$startsWith": 1}, err: ErrorFilterValue},
+		{name: "regexp not string", filters: map[string]interface{}{"code$regexp": []string{"a"}}, err: ErrorFilterValue},
+	})
+}
+
+func TestFilterLikeEscaping(t *testing.T) {
+	runFilterCases(t, []filterCase{
+		{
+			name:    "like",
+			filters: map[string]interface{}{"name$like": "50%_off!"},
+			sql:     "SELECT * FROM `test_orders` WHERE `test_orders`.`name` like ? escape '!'",
+			vars:    []interface{}{"%50!%!_off!!%"},
+		},
+		{
+			name:    "not like",
+			filters: map[string]interface{}{"name$notLike": "a_b"},
+			sql:     "SELECT * FROM `test_orders` WHERE `test_orders`.`name` not like ? escape '!'",
+			vars:    []interface{}{"%a!_b%"},
+		},
+		{
+			name:    "like non string",
+			filters: map[string]interface{}{"name$like": 5},
+			sql:     "SELECT * FROM `test_orders` WHERE `test_orders`.`name` like ? escape '!'",
+			vars:    []interface{}{"%5%"},
+		},
+		{
+			name:    "starts with",
+			filters: map[string]interface{}{"code$startsWith": "A%"},
+			sql:     "SELECT * FROM `test_orders` WHERE `test_orders`.`code` like ? escape '!'",
+			vars:    []interface{}{"A!%%"},
+		},
+		{
+			name:    "ends with",
+			filters: map[string]interface{}{"code$endsWith": "_1"},
+			sql:     "SELECT * FROM `test_orders` WHERE `test_orders`.`code` like ? escape '!'",
+			vars:    []interface{}{"%!_1"},
+		},
+		{
+			name:    "ilike",
+			filters: map[string]interface{}{"name$ilike": "Ab%"},
+			sql:     "SELECT * FROM `test_orders` WHERE `test_orders`.`name` collate utf8mb4_general_ci like ? escape '!'",
+			vars:    []interface{}{"%Ab!%%"},
+		},
+		{
+			name:    "raw pattern",
+			filters: map[string]interface{}{"name$likeRaw": "a%b_"},
+			sql:     "SELECT * FROM `test_orders` WHERE `test_orders`.`name` like ?",
+			vars:    []interface{}{"a%b_"},
+		},
+		{name: "raw pattern not string", filters: map[string]interface{}{"name$likeRaw": 1}, err: ErrorFilterValue},
+	})
+}
+
+func TestFilterMultiColumn(t *testing.T) {
+	runFilterCases(t, []filterCase{
+		{
+			name:    "like",
+			filters: map[string]interface{}{"name|code|phone$like": "a_", "amount": 1},
+			sql: "SELECT * FROM `test_orders` WHERE `test_orders`.`amount` = ? AND " +
+				"(`test_orders`.`name` like ? escape '!' OR `test_orders`.`code` like ? escape '!' OR `test_orders`.`phone` like ? escape '!')",
+			vars: []interface{}{1, "%a!_%", "%a!_%", "%a!_%"},
+		},
+		{
+			name:    "default operator",
+			filters: map[string]interface{}{"name|code": []string{"a", "b"}},
+			sql:     "SELECT * FROM `test_orders` WHERE (`test_orders`.`name` in (?,?) OR `test_orders`.`code` in (?,?))",
+			vars:    []interface{}{"a", "b", "a", "b"},
+		},
+		{
+			name:    "inside a group",
+			filters: map[string]interface{}{"$not": map[string]interface{}{"name|code$eq": "a"}},
+			sql:     "SELECT * FROM `test_orders` WHERE NOT ((`test_orders`.`name` = ? OR `test_orders`.`code` = ?))",
+			vars:    []interface{}{"a", "a"},
+		},
+		{name: "hidden column", filters: map[string]interface{}{"name|password$like": "a"}, err: ErrorFilterColumn},
+		{name: "empty column", filters: map[string]interface{}{"name|$like": "a"}, err: ErrorFilterColumn},
+		{name: "shape checked per column", filters: map[string]interface{}{"name|code$startsWith": 1}, err: ErrorFilterValue},
+	})
+}
+
+type testCompany struct {
+	Id   int64  `gorm:"primary_key" json:"id"`
+	City string `json:"city"`
+}
+
+type testUser struct {
+	Id        int64        `gorm:"primary_key" json:"id"`
+	Name      string       `json:"name"`
+	Password  string       `json:"-"`
+	CompanyId int64        `json:"companyId"`
+	Company   *testCompany `json:"company"`
+}
+
+type testProfile struct {
+	Id          int64  `gorm:"primary_key" json:"id"`
+	TestOrderId int64  `json:"testOrderId"`
+	Bio         string `json:"bio"`
+}
+
+type testItem struct {
+	Id          int64  `gorm:"primary_key" json:"id"`
+	TestOrderId int64  `json:"testOrderId"`
+	Name        string `json:"name"`
+}
+
+// testRelOrder maps to the test_orders table like testOrder, with associations.
+type testRelOrder struct {
+	Id      int64        `gorm:"primary_key" json:"id"`
+	Status  int          `json:"status"`
+	UserId  int64        `json:"userId"`
+	User    *testUser    `json:"user"`
+	Profile *testProfile `json:"profile" gorm:"foreignKey:TestOrderId"`
+	Items   []*testItem  `json:"items" gorm:"foreignKey:TestOrderId"`
+	OwnerId int64        `json:"ownerId"`
+	Owner   *testUser    `json:"-"`
+	AuditId int64        `json:"auditId"`
+	Audit   *testUser    `json:"audit" filter:"-"`
+}
+
+func (testRelOrder) TableName() string {
+	return "test_orders"
+}
+
+func TestFilterRelations(t *testing.T) {
+	const columns = "SELECT `test_orders`.`id`,`test_orders`.`status`,`test_orders`.`user_id`,`test_orders`.`owner_id`,`test_orders`.`audit_id` FROM `test_orders` "
+	cases := []struct {
+		name    string
+		opts    []Option
+		filters map[string]interface{}
+		sql     string
+		vars    []interface{}
+		err     error
+	}{
+		{
+			name:    "belongs to",
+			filters: map[string]interface{}{"user.name$like": "a", "status": 1},
+			sql: columns + "LEFT JOIN `test_users` `User` ON `test_orders`.`user_id` = `User`.`id` " +
+				"WHERE `test_orders`.`status` = ? AND `User`.`name` like ? escape '!'",
+			vars: []interface{}{1, "%a%"},
+		},
+		{
+			name:    "joined once",
+			filters: map[string]interface{}{"user.name": "a", "User.id$gt": 1},
+			sql: columns + "LEFT JOIN `test_users` `User` ON `test_orders`.`user_id` = `User`.`id` " +
+				"WHERE `User`.`id` > ? AND `User`.`name` = ?",
+			vars: []interface{}{1, "a"},
+		},
+		{
+			name:    "nested",
+			filters: map[string]interface{}{"user.company.city": "x"},
+			sql: columns + "LEFT JOIN `test_users` `User` ON `test_orders`.`user_id` = `User`.`id` " +
+				"LEFT JOIN `test_companies` `User__Company` ON `User`.`company_id` = `User__Company`.`id` " +
+				"WHERE `User__Company`.`city` = ?",
+			vars: []interface{}{"x"},
+		},
+		{
+			name:    "has one",
+			filters: map[string]interface{}{"profile.bio$startsWith": "b"},
+			sql: columns + "LEFT JOIN `test_profiles` `Profile` ON `test_orders`.`id` = `Profile`.`test_order_id` " +
+				"WHERE `Profile`.`bio` like ? escape '!'",
+			vars: []interface{}{"b%"},
+		},
+		{
+			name:    "inside a group",
+			filters: map[string]interface{}{"$or": []interface{}{map[string]interface{}{"user.name": "a"}, map[string]interface{}{"status": 1}}},
+			sql: columns + "LEFT JOIN `test_users` `User` ON `test_orders`.`user_id` = `User`.`id` " +
+				"WHERE (`User`.`name` = ? OR `test_orders`.`status` = ?)",
+			vars: []interface{}{"a", 1},
+		},
+		{
+			name:    "multi column",
+			filters: map[string]interface{}{"user.name|user.company.city$eq": "a"},
+			sql: columns + "LEFT JOIN `test_users` `User` ON `test_orders`.`user_id` = `User`.`id` " +
+				"LEFT JOIN `test_companies` `User__Company` ON `User`.`company_id` = `User__Company`.`id` " +
+				"WHERE (`User`.`name` = ? OR `User__Company`.`city` = ?)",
+			vars: []interface{}{"a", "a"},
+		},
+		{
+			name:    "reuses association join",
+			opts:    []Option{WithJoin("User")},
+			filters: map[string]interface{}{"user.name": "a"},
+			sql: "SELECT `test_orders`.`id`,`test_orders`.`status`,`test_orders`.`user_id`,`test_orders`.`owner_id`,`test_orders`.`audit_id`," +
+				"`User`.`id` AS `User__id`,`User`.`name` AS `User__name`,`User`.`password` AS `User__password`,`User`.`company_id` AS `User__company_id` " +
+				"FROM `test_orders` LEFT JOIN `test_users` `User` ON `test_orders`.`user_id` = `User`.`id` WHERE `User`.`name` = ?",
+			vars: []interface{}{"a"},
+		},
+		{
+			name:    "raw join of the same table",
+			opts:    []Option{WithJoin("LEFT JOIN test_users AS o ON o.id = test_orders.owner_id")},
+			filters: map[string]interface{}{"user.name": "a"},
+			sql: columns + "LEFT JOIN test_users AS o ON o.id = test_orders.owner_id " +
+				"LEFT JOIN `test_users` `User` ON `test_orders`.`user_id` = `User`.`id` WHERE `User`.`name` = ?",
+			vars: []interface{}{"a"},
+		},
+		{name: "has many", filters: map[string]interface{}{"items.name": "a"}, err: ErrorFilterColumn},
+		{name: "hidden column", filters: map[string]interface{}{"user.password": "a"}, err: ErrorFilterColumn},
+		{name: "json hidden association", filters: map[string]interface{}{"owner.name": "a"}, err: ErrorFilterColumn},
+		{name: "json hidden association by field", filters: map[string]interface{}{"Owner.name": "a"}, err: ErrorFilterColumn},
+		{name: "excluded association", filters: map[string]interface{}{"audit.name": "a"}, err: ErrorFilterColumn},
+		{name: "empty path segment", filters: map[string]interface{}{"user..name": "a"}, err: ErrorFilterColumn},
+		{name: "unknown association", filters: map[string]interface{}{"shop.city": "a"}, err: ErrorFilterColumn},
+		{name: "custom table", opts: []Option{WithTable("test_orders o")}, filters: map[string]interface{}{"user.name": "a"}, err: ErrorFilterColumn},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			opts := append(c.opts, WithFilters(c.filters))
+			sql, vars, err := dryRunFind(t, newDryRunDB(t), &[]*testRelOrder{}, opts...)
+			if c.err != nil {
+				if !errors.Is(err, c.err) {
+					t.Fatalf("err = %v, want %v", err, c.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sql != c.sql {
+				t.Errorf("sql = %s\nwant  %s", sql, c.sql)
+			}
+			if !reflect.DeepEqual(vars, c.vars) {
+				t.Errorf("vars = %#v, want %#v", vars, c.vars)
+			}
+		})
+	}
+}
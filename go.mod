@@ -5,19 +5,20 @@ go 1.22.0
 toolchain go1.22.8
 
 require (
+	github.com/fsnotify/fsnotify v1.7.0
 	github.com/go-estar/base-error v1.0.7
 	github.com/go-estar/config v1.0.0
 	github.com/go-estar/logger v1.0.1
 	github.com/go-estar/types v1.0.3
 	github.com/go-sql-driver/mysql v1.8.1
 	github.com/pkg/errors v0.9.1
+	github.com/shopspring/decimal v1.4.0
 	gorm.io/driver/mysql v1.5.7
 	gorm.io/gorm v1.25.12
 )
 
 require (
 	filippo.io/edwards25519 v1.1.0 // indirect
-	github.com/fsnotify/fsnotify v1.7.0 // indirect
 	github.com/hashicorp/hcl v1.0.0 // indirect
 	github.com/jinzhu/inflection v1.0.0 // indirect
 	github.com/jinzhu/now v1.1.5 // indirect
@@ -26,7 +27,6 @@ require (
 	github.com/pelletier/go-toml/v2 v2.2.3 // indirect
 	github.com/sagikazarmark/locafero v0.6.0 // indirect
 	github.com/sagikazarmark/slog-shim v0.1.0 // indirect
-	github.com/shopspring/decimal v1.4.0 // indirect
 	github.com/sourcegraph/conc v0.3.0 // indirect
 	github.com/spf13/afero v1.11.0 // indirect
 	github.com/spf13/cast v1.7.0 // indirect
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	goLogger "github.com/go-estar/logger"
+	"gorm.io/gorm"
 	gormLogger "gorm.io/gorm/logger"
 	"gorm.io/gorm/utils"
 	"io"
@@ -14,6 +15,7 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -215,6 +217,61 @@ func (l *traceRecorder) Trace(ctx context.Context, begin time.Time, fc func() (s
 	l.Err = err
 }
 
+// SwitchLogger delegates to a logger which can be replaced at runtime
+type SwitchLogger struct {
+	current atomic.Pointer[gormLogger.Interface]
+}
+
+// NewSwitchLogger initialize switch logger
+func NewSwitchLogger(l gormLogger.Interface) *SwitchLogger {
+	s := &SwitchLogger{}
+	s.Set(l)
+	return s
+}
+
+// Set replace the logger
+func (s *SwitchLogger) Set(l gormLogger.Interface) {
+	s.current.Store(&l)
+}
+
+// Get return the current logger
+func (s *SwitchLogger) Get() gormLogger.Interface {
+	return *s.current.Load()
+}
+
+// LogMode log mode of the current logger, not affected by later Set
+func (s *SwitchLogger) LogMode(level LogLevel) gormLogger.Interface {
+	return s.Get().LogMode(level)
+}
+
+// Info print info
+func (s *SwitchLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	s.Get().Info(ctx, msg, data...)
+}
+
+// Warn print warn messages
+func (s *SwitchLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	s.Get().Warn(ctx, msg, data...)
+}
+
+// Error print error messages
+func (s *SwitchLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	s.Get().Error(ctx, msg, data...)
+}
+
+// Trace print sql message
+func (s *SwitchLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	s.Get().Trace(ctx, begin, fc, err)
+}
+
+// ParamsFilter filter params by the current logger
+func (s *SwitchLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	if filter, ok := s.Get().(gorm.ParamsFilter); ok {
+		return filter.ParamsFilter(ctx, sql, params...)
+	}
+	return sql, params
+}
+
 // FileWithLineNum return the file name and line number of the current file
 func FileWithLineNum() string {
 	// the second caller usually from gorm internal, so set i start from 2
@@ -1,28 +1,100 @@
 package mysql
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"github.com/go-estar/config"
 	goLogger "github.com/go-estar/logger"
 	"github.com/go-estar/types/stringUtil"
+	mysqlDriver "github.com/go-sql-driver/mysql"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	gormLogger "gorm.io/gorm/logger"
 	"gorm.io/gorm/schema"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
 type DB struct {
 	*gorm.DB
+	config   *Config
+	replicas []*gorm.DB
+	sticky   *stickyKeys
+	logger   *SwitchLogger
+}
+
+func (db *DB) session(g *gorm.DB) *DB {
+	clone := *db
+	clone.DB = g
+	return &clone
+}
+
+func (db *DB) ctx() context.Context {
+	return contextOf(db.DB)
+}
+
+func contextOf(g *gorm.DB) context.Context {
+	if g.Statement != nil && g.Statement.Context != nil {
+		return g.Statement.Context
+	}
+	return context.Background()
+}
+
+// Ctx returns a DB whose queries run with ctx, so deadlines and cancellation
+// of the caller reach the driver. Unlike the embedded gorm WithContext it keeps
+// the replicas, sticky reads and transaction joining of db.
+func (db *DB) Ctx(ctx context.Context) *DB {
+	return db.session(db.DB.WithContext(ctx))
 }
 
 type Config struct {
-	Dialect        string `json:"dialect"`
-	Url            string `json:"url"`
-	MaxIdleConns   int    `json:"maxIdleConns"`
-	MaxOpenConns   int    `json:"maxOpenConns"`
-	Debug          bool   `json:"debug"`
+	Dialect        string   `json:"dialect"`
+	Url            string   `json:"url"`
+	Replicas       []string `json:"replicas"`
+	MaxIdleConns   int      `json:"maxIdleConns"`
+	MaxOpenConns   int      `json:"maxOpenConns"`
+	Debug          bool     `json:"debug"`
 	Logger         goLogger.Logger
 	NamingStrategy *schema.NamingStrategy
+	ReplicaPolicy  ReplicaPolicy
+	// SlowThreshold of the SQL log, defaults to 200ms.
+	SlowThreshold time.Duration
+	// StickyWindow routes reads of a sticky key to the primary for this long
+	// after a write made with the same key, see ContextWithStickyKey.
+	StickyWindow time.Duration
+	// ConnMaxLifetime defaults to 10 minutes.
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	DialTimeout     time.Duration
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	// TLS is the driver tls parameter, e.g. "true" or "skip-verify", set
+	// implicitly by TLSCAFile.
+	TLS           string
+	TLSCAFile     string
+	TLSServerName string
+	// SessionVariables are set on every new connection, e.g. time_zone,
+	// sql_mode and group_concat_max_len. Non numeric values are quoted.
+	SessionVariables map[string]string
+	Charset          string
+	Collation        string
+	// DeferredJoinOffset turns on WithDeferredJoin for FindPage offsets from it
+	// on, 0 disables it.
+	DeferredJoinOffset int
+	// ConnectRetry retries connecting on startup, any error is retried unless
+	// it sets Retryable. Unset backoffs are taken from DefaultConnectRetryPolicy.
+	ConnectRetry *RetryPolicy
+	// LazyConnect skips connecting on startup.
+	LazyConnect bool
+	// TransactionRetry re-runs a whole Transaction closure on deadlocks and
+	// lock wait timeouts. Nil disables retrying.
+	TransactionRetry *RetryPolicy
+	// SlowTransactionThreshold logs a warning for transactions open longer than it.
+	SlowTransactionThreshold time.Duration
 }
 
 type ConfigOption func(*Config)
@@ -32,20 +104,123 @@ func WithNamingStrategy(val *schema.NamingStrategy) ConfigOption {
 		config.NamingStrategy = val
 	}
 }
+func WithReplicaPolicy(val ReplicaPolicy) ConfigOption {
+	return func(config *Config) {
+		config.ReplicaPolicy = val
+	}
+}
+func WithConnectRetry(val *RetryPolicy) ConfigOption {
+	return func(config *Config) {
+		config.ConnectRetry = val
+	}
+}
+func WithLazyConnect() ConfigOption {
+	return func(config *Config) {
+		config.LazyConnect = true
+	}
+}
+func WithTransactionRetry(val *RetryPolicy) ConfigOption {
+	return func(config *Config) {
+		config.TransactionRetry = val
+	}
+}
+
+// NewWithConfig is OpenWithConfig panicking on errors.
 func NewWithConfig(c *config.Config, opts ...ConfigOption) *DB {
-	return New(&Config{
-		Dialect:      c.GetString("database.dialect"),
-		Url:          c.GetString("database.url"),
-		MaxIdleConns: c.GetInt("database.maxIdleConns"),
-		MaxOpenConns: c.GetInt("database.maxOpenConns"),
-		Debug:        c.GetBool("database.debug"),
-		Logger:       goLogger.NewZapWithConfig(c, "mysql", "error"),
-	}, opts...)
+	db, err := OpenWithConfig(c, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return db
+}
+
+// OpenWithConfig opens the DB configured by the database.* keys and applies
+// later changes of the pool sizes, debug and slowThreshold keys at runtime.
+// It takes over the OnConfigChange callback of c, see OnConfigChange.
+func OpenWithConfig(c *config.Config, opts ...ConfigOption) (*DB, error) {
+	db, err := Open(readConfig(c, "database", "mysql"), opts...)
+	if err != nil {
+		return nil, err
+	}
+	db.watchConfig(c, "database")
+	return db, nil
+}
+
+// readConfig reads the Config under prefix. Settings missing under a named
+// database.<name> prefix fall back to the shared database.* ones, except
+// for url and replicas.
+func readConfig(c *config.Config, prefix string, loggerName string) *Config {
+	key := func(name string) string {
+		return configKey(c, prefix, name)
+	}
+	conf := &Config{
+		Dialect:      c.GetString(key("dialect")),
+		Url:          c.GetString(prefix + ".url"),
+		Replicas:     c.GetStringSlice(prefix + ".replicas"),
+		MaxIdleConns: c.GetInt(key("maxIdleConns")),
+		MaxOpenConns: c.GetInt(key("maxOpenConns")),
+		Debug:        c.GetBool(key("debug")),
+		Logger:       goLogger.NewZapWithConfig(c, loggerName, "error"),
+
+		SlowThreshold:            c.GetDuration(key("slowThreshold")),
+		StickyWindow:             c.GetDuration(key("stickyWindow")),
+		SlowTransactionThreshold: c.GetDuration(key("slowTransactionThreshold")),
+	}
+	if c.GetString(key("replicaPolicy")) == "roundRobin" {
+		conf.ReplicaPolicy = &RoundRobinPolicy{}
+	}
+	conf.ConnMaxLifetime = c.GetDuration(key("connMaxLifetime"))
+	conf.ConnMaxIdleTime = c.GetDuration(key("connMaxIdleTime"))
+	conf.DialTimeout = c.GetDuration(key("dialTimeout"))
+	conf.ReadTimeout = c.GetDuration(key("readTimeout"))
+	conf.WriteTimeout = c.GetDuration(key("writeTimeout"))
+	conf.TLS = c.GetString(key("tls"))
+	conf.TLSCAFile = c.GetString(key("tlsCAFile"))
+	conf.TLSServerName = c.GetString(key("tlsServerName"))
+	conf.SessionVariables = c.GetStringMapString(key("sessionVariables"))
+	conf.Charset = c.GetString(key("charset"))
+	conf.Collation = c.GetString(key("collation"))
+	conf.DeferredJoinOffset = c.GetInt(key("deferredJoinOffset"))
+	conf.LazyConnect = c.GetBool(key("lazyConnect"))
+	if attempts := c.GetInt(key("connectRetry.maxAttempts")); attempts > 0 {
+		conf.ConnectRetry = &RetryPolicy{
+			MaxAttempts: attempts,
+			MinBackoff:  c.GetDuration(key("connectRetry.minBackoff")),
+			MaxBackoff:  c.GetDuration(key("connectRetry.maxBackoff")),
+		}
+	}
+	if attempts := c.GetInt(key("transactionRetry.maxAttempts")); attempts > 0 {
+		conf.TransactionRetry = &RetryPolicy{
+			MaxAttempts: attempts,
+			MinBackoff:  c.GetDuration(key("transactionRetry.minBackoff")),
+			MaxBackoff:  c.GetDuration(key("transactionRetry.maxBackoff")),
+		}
+	}
+	return conf
 }
 
+func configKey(c *config.Config, prefix string, name string) string {
+	if k := prefix + "." + name; prefix == "database" || c.IsSet(k) {
+		return k
+	}
+	return "database." + name
+}
+
+// New is Open panicking on errors.
 func New(c *Config, opts ...ConfigOption) *DB {
+	db, err := Open(c, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return db
+}
+
+// Open connects to the primary and the replicas, retrying per
+// Config.ConnectRetry. With Config.LazyConnect it only validates the settings
+// and connections are made by the first queries.
+func Open(c *Config, opts ...ConfigOption) (*DB, error) {
 	if c == nil {
-		panic("c 必须设置")
+		return nil, WithStack(ErrorConfigUnset)
 	}
 	for _, apply := range opts {
 		if apply != nil {
@@ -53,10 +228,10 @@ func New(c *Config, opts ...ConfigOption) *DB {
 		}
 	}
 	if c.Url == "" {
-		panic("Url 必须设置")
+		return nil, WithStack(ErrorUrlUnset)
 	}
 	if c.Logger == nil {
-		panic("Logger 必须设置")
+		return nil, WithStack(ErrorLoggerUnset)
 	}
 
 	if c.NamingStrategy == nil {
@@ -65,31 +240,164 @@ func New(c *Config, opts ...ConfigOption) *DB {
 		}
 	}
 	conf := &gorm.Config{
-		NamingStrategy: c.NamingStrategy,
+		NamingStrategy:       c.NamingStrategy,
+		DisableAutomaticPing: c.LazyConnect,
+	}
+	logger := NewSwitchLogger(newGormLogger(c))
+	conf.Logger = logger
+	db, err := open(c, c.Url, conf)
+	if err != nil {
+		return nil, err
+	}
+	replicas := make([]*gorm.DB, 0, len(c.Replicas))
+	for _, url := range c.Replicas {
+		replica, err := open(c, url, conf)
+		if err != nil {
+			for _, opened := range append(replicas, db) {
+				if dbConfig, e := opened.DB(); e == nil {
+					dbConfig.Close()
+				}
+			}
+			return nil, err
+		}
+		replicas = append(replicas, replica)
 	}
+	if c.ReplicaPolicy == nil {
+		c.ReplicaPolicy = &RandomPolicy{}
+	}
+	return &DB{DB: db, config: c, replicas: replicas, sticky: newStickyKeys(c.StickyWindow), logger: logger}, nil
+}
+
+func newGormLogger(c *Config) gormLogger.Interface {
 	if c.Debug {
-		conf.Logger = DefaultLogger.LogMode(Info)
-	} else {
-		conf.Logger = NewLogger(&DBLogger{c.Logger}, gormLogger.Config{
-			SlowThreshold:             200 * time.Millisecond,
-			LogLevel:                  Error,
-			IgnoreRecordNotFoundError: true,
-			Colorful:                  false,
-		})
-	}
-	db, err := gorm.Open(mysql.Open(c.Url), conf)
+		return DefaultLogger.LogMode(Info)
+	}
+	slowThreshold := c.SlowThreshold
+	if slowThreshold == 0 {
+		slowThreshold = 200 * time.Millisecond
+	}
+	return NewLogger(&DBLogger{c.Logger}, gormLogger.Config{
+		SlowThreshold:             slowThreshold,
+		LogLevel:                  Error,
+		IgnoreRecordNotFoundError: true,
+		Colorful:                  false,
+	})
+}
+
+func open(c *Config, url string, conf *gorm.Config) (*gorm.DB, error) {
+	policy := c.ConnectRetry
+	if policy != nil {
+		retry := *policy
+		if retry.Retryable == nil {
+			retry.Retryable = func(err error) bool { return true }
+		}
+		if retry.MinBackoff <= 0 {
+			retry.MinBackoff = DefaultConnectRetryPolicy.MinBackoff
+		}
+		if retry.MaxBackoff <= 0 {
+			retry.MaxBackoff = DefaultConnectRetryPolicy.MaxBackoff
+		}
+		policy = &retry
+	}
+	dsn, err := formatDSN(c, url)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	dbConfig, _ := db.DB()
-	dbConfig.SetConnMaxLifetime(time.Minute * 10)
-	dbConfig.SetMaxIdleConns(c.MaxIdleConns)
-	dbConfig.SetMaxOpenConns(c.MaxOpenConns)
+	var db *gorm.DB
+	err = policy.run(context.Background(), func() (err error) {
+		db, err = gorm.Open(mysql.New(mysql.Config{
+			DSN:                       dsn,
+			SkipInitializeWithVersion: c.LazyConnect,
+		}), conf)
+		if err != nil {
+			return WithStack(err)
+		}
+		dbConfig, _ := db.DB()
+		connMaxLifetime := c.ConnMaxLifetime
+		if connMaxLifetime == 0 {
+			connMaxLifetime = time.Minute * 10
+		}
+		dbConfig.SetConnMaxLifetime(connMaxLifetime)
+		dbConfig.SetConnMaxIdleTime(c.ConnMaxIdleTime)
+		dbConfig.SetMaxIdleConns(c.MaxIdleConns)
+		dbConfig.SetMaxOpenConns(c.MaxOpenConns)
 
-	if err := dbConfig.Ping(); err != nil {
-		panic(err)
+		if c.LazyConnect {
+			return nil
+		}
+		if err := dbConfig.Ping(); err != nil {
+			dbConfig.Close()
+			return WithStack(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+var tlsConfigSeq uint64
+
+// formatDSN applies the timeouts, TLS, charset, collation and session
+// variables of c to url.
+func formatDSN(c *Config, url string) (string, error) {
+	dsn, err := mysqlDriver.ParseDSN(url)
+	if err != nil {
+		return "", WithStack(err)
+	}
+	if c.DialTimeout > 0 {
+		dsn.Timeout = c.DialTimeout
+	}
+	if c.ReadTimeout > 0 {
+		dsn.ReadTimeout = c.ReadTimeout
+	}
+	if c.WriteTimeout > 0 {
+		dsn.WriteTimeout = c.WriteTimeout
+	}
+	if c.Collation != "" {
+		dsn.Collation = c.Collation
+	}
+	if dsn.Params == nil {
+		dsn.Params = map[string]string{}
+	}
+	if c.Charset != "" {
+		dsn.Params["charset"] = c.Charset
+	}
+	for name, value := range c.SessionVariables {
+		dsn.Params[name] = sessionValue(value)
+	}
+	if c.TLSCAFile != "" {
+		pem, err := os.ReadFile(c.TLSCAFile)
+		if err != nil {
+			return "", WithStack(err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return "", WithStack(ErrorTLSCA)
+		}
+		name := "estar-" + strconv.FormatUint(atomic.AddUint64(&tlsConfigSeq, 1), 10)
+		if err := mysqlDriver.RegisterTLSConfig(name, &tls.Config{
+			RootCAs:    pool,
+			ServerName: c.TLSServerName,
+		}); err != nil {
+			return "", WithStack(err)
+		}
+		dsn.TLSConfig = name
+	} else if c.TLS != "" {
+		dsn.TLSConfig = c.TLS
+	}
+	return dsn.FormatDSN(), nil
+}
+
+func sessionValue(value string) string {
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return value
+	}
+	if len(value) >= 2 && strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'") {
+		return value
 	}
-	return &DB{DB: db}
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
 }
 
 type CamelCaseReplacer struct {
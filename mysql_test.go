@@ -0,0 +1,73 @@
+package mysql
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	mysqlDriver "github.com/go-sql-driver/mysql"
+)
+
+func TestSessionValue(t *testing.T) {
+	cases := map[string]string{
+		"1000":           "1000",
+		"-1.5":           "-1.5",
+		"READ-COMMITTED": "'READ-COMMITTED'",
+		"'utf8mb4'":      "'utf8mb4'",
+		"it's":           "'it''s'",
+		"'":              "''''",
+		"":               "''",
+	}
+	for value, want := range cases {
+		if got := sessionValue(value); got != want {
+			t.Errorf("sessionValue(%q) = %s, want %s", value, got, want)
+		}
+	}
+}
+
+func TestFormatDSN(t *testing.T) {
+	c := &Config{
+		DialTimeout:      time.Second,
+		ReadTimeout:      2 * time.Second,
+		WriteTimeout:     3 * time.Second,
+		Charset:          "utf8mb4",
+		Collation:        "utf8mb4_general_ci",
+		TLS:              "skip-verify",
+		SessionVariables: map[string]string{"sql_mode": "TRADITIONAL", "wait_timeout": "60"},
+	}
+	dsn, err := formatDSN(c, "user:pass@tcp(127.0.0.1:3306)/db?parseTime=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := mysqlDriver.ParseDSN(dsn)
+	if err != nil {
+		t.Fatalf("%s: %v", dsn, err)
+	}
+	if got.Timeout != time.Second || got.ReadTimeout != 2*time.Second || got.WriteTimeout != 3*time.Second {
+		t.Errorf("timeouts = %s %s %s", got.Timeout, got.ReadTimeout, got.WriteTimeout)
+	}
+	if got.Collation != c.Collation || got.TLSConfig != c.TLS || !got.ParseTime {
+		t.Errorf("dsn = %s", dsn)
+	}
+	want := map[string]string{"charset": "utf8mb4", "sql_mode": "'TRADITIONAL'", "wait_timeout": "60"}
+	for name, value := range want {
+		if got.Params[name] != value {
+			t.Errorf("param %s = %q, want %q", name, got.Params[name], value)
+		}
+	}
+}
+
+func TestFormatDSNErrors(t *testing.T) {
+	if _, err := formatDSN(&Config{}, "not a dsn"); err == nil {
+		t.Error("invalid dsn accepted")
+	}
+	ca := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(ca, []byte("no certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := formatDSN(&Config{TLSCAFile: ca}, "user:pass@tcp(127.0.0.1:3306)/db"); !errors.Is(err, ErrorTLSCA) {
+		t.Errorf("err = %v, want %v", err, ErrorTLSCA)
+	}
+}
@@ -1,8 +1,11 @@
 package mysql
 
 import (
+	"context"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"reflect"
+	"strings"
 )
 
 type Option func(*QueryOption)
@@ -38,6 +41,7 @@ type Select struct {
 }
 type QueryOption struct {
 	DB               *gorm.DB
+	Context          context.Context
 	Table            string
 	PrimaryKey       string
 	Updates          map[string]interface{}
@@ -46,6 +50,7 @@ type QueryOption struct {
 	IgnoreOmit       bool
 	Attend           []string
 	Join             [][]interface{}
+	SelectJoin       [][]interface{}
 	Where            [][]interface{}
 	Or               [][]interface{}
 	Filters          map[string]interface{}
@@ -53,6 +58,11 @@ type QueryOption struct {
 	Limit            int
 	Offset           int
 	Pageable         *Pageable
+	CursorPageable   *CursorPageable
+	CountStrategy    CountStrategy
+	ConcurrentCount  bool
+	DeferredJoin     *int
+	Aggregates       []Aggregate
 	Sort             []string
 	Pluck            []interface{}
 	Dest             interface{}
@@ -64,6 +74,12 @@ type QueryOption struct {
 	ErrorNotFound    error
 	ErrorNotAffected error
 	ErrorNotSingle   error
+	Primary          bool
+	read             bool
+
+	// IgnoreFilterWhitelist accepts filters on any column or expression, only
+	// set it for filters built by code.
+	IgnoreFilterWhitelist bool
 }
 
 func WithDB(val *gorm.DB) Option {
@@ -71,6 +87,17 @@ func WithDB(val *gorm.DB) Option {
 		opts.DB = val
 	}
 }
+func WithContext(val context.Context) Option {
+	return func(opts *QueryOption) {
+		opts.Context = val
+	}
+}
+// WithPrimary routes a read to the primary instead of a replica.
+func WithPrimary() Option {
+	return func(opts *QueryOption) {
+		opts.Primary = true
+	}
+}
 func WithTable(val string) Option {
 	return func(opts *QueryOption) {
 		opts.Table = val
@@ -106,6 +133,11 @@ func WithIgnoreOmit() Option {
 		opts.IgnoreOmit = true
 	}
 }
+func WithIgnoreFilterWhitelist() Option {
+	return func(opts *QueryOption) {
+		opts.IgnoreFilterWhitelist = true
+	}
+}
 func WithOmit(val ...string) Option {
 	return func(opts *QueryOption) {
 		opts.Omit = append(opts.Omit, val...)
@@ -138,6 +170,13 @@ func WithJoinCondition(condition bool, query string, val ...interface{}) Option
 		}
 	}
 }
+// WithSelectJoin joins a table only needed by the selected columns, it is
+// left out of the count query of FindPage.
+func WithSelectJoin(query string, val ...interface{}) Option {
+	return func(opts *QueryOption) {
+		opts.SelectJoin = append(opts.SelectJoin, []interface{}{query, val})
+	}
+}
 func WithWhere(val ...interface{}) Option {
 	return func(opts *QueryOption) {
 		opts.Where = append(opts.Where, val)
@@ -217,6 +256,49 @@ func WithPageable(val *Pageable) Option {
 		opts.Pageable = val
 	}
 }
+func WithCursorPage(cursor string, size int, sort string) Option {
+	return func(opts *QueryOption) {
+		opts.CursorPageable = &CursorPageable{
+			Cursor: cursor, Size: size, Sort: sort,
+		}
+	}
+}
+func WithCursorPageable(val *CursorPageable) Option {
+	return func(opts *QueryOption) {
+		opts.CursorPageable = val
+	}
+}
+func WithCountStrategy(val CountStrategy) Option {
+	return func(opts *QueryOption) {
+		opts.CountStrategy = val
+	}
+}
+// WithConcurrentCount runs the list and count queries of FindPage concurrently
+// on separate connections, outside transactions only.
+func WithConcurrentCount() Option {
+	return func(opts *QueryOption) {
+		opts.ConcurrentCount = true
+	}
+}
+// WithDeferredJoin makes FindPage select the primary keys of a page first and
+// join the rows back once the offset reaches minOffset, 0 always does.
+// Config.DeferredJoinOffset sets the default.
+func WithDeferredJoin(minOffset int) Option {
+	return func(opts *QueryOption) {
+		opts.DeferredJoin = &minOffset
+	}
+}
+// WithAggregate adds an aggregate to the summary FindPage reports with the page.
+func WithAggregate(name string, fn AggregateFunc, column string) Option {
+	return func(opts *QueryOption) {
+		opts.Aggregates = append(opts.Aggregates, Aggregate{Name: name, Func: fn, Column: column})
+	}
+}
+func WithAggregates(val ...Aggregate) Option {
+	return func(opts *QueryOption) {
+		opts.Aggregates = append(opts.Aggregates, val...)
+	}
+}
 func WithSort(val ...string) Option {
 	return func(opts *QueryOption) {
 		opts.Sort = append(opts.Sort, val...)
@@ -281,9 +363,22 @@ func (db *DB) queryBuilder(model interface{}, opts ...Option) (*gorm.DB, *QueryO
 		}
 	}
 
+	ctx := queryOption.Context
+	if ctx == nil {
+		ctx = db.ctx()
+	}
 	var query *gorm.DB
 	if queryOption.DB != nil {
 		query = queryOption.DB
+		if queryOption.Context != nil {
+			query = query.WithContext(ctx)
+		}
+	} else if state, ok := db.txFromContext(ctx); ok && !db.InTransaction() {
+		query = state.tx.WithContext(ctx)
+	} else if replica := db.replica(ctx, queryOption); replica != nil {
+		query = replica.WithContext(ctx)
+	} else if queryOption.Context != nil {
+		query = db.DB.WithContext(ctx)
 	} else {
 		query = db.DB
 	}
@@ -302,8 +397,8 @@ func (db *DB) queryBuilder(model interface{}, opts ...Option) (*gorm.DB, *QueryO
 		query = query.Omit(queryOption.Omit...)
 	}
 
-	if len(queryOption.Join) > 0 {
-		for _, joins := range queryOption.Join {
+	if len(queryOption.Join) > 0 || len(queryOption.SelectJoin) > 0 {
+		for _, joins := range append(queryOption.Join, queryOption.SelectJoin...) {
 			if joins == nil || joins[0] == nil {
 				continue
 			}
@@ -368,7 +463,7 @@ func (db *DB) queryBuilder(model interface{}, opts ...Option) (*gorm.DB, *QueryO
 	if queryOption.Pageable != nil && queryOption.Pageable.Size > 0 {
 		query = query.Limit(queryOption.Pageable.Size).Offset((queryOption.Pageable.Page - 1) * queryOption.Pageable.Size)
 		if queryOption.Pageable.Sort != "" {
-			query = query.Order(queryOption.Pageable.Sort)
+			query = db.safeSort(query, model, queryOption, queryOption.Pageable.Sort)
 		}
 	}
 	if queryOption.Limit != 0 {
@@ -393,19 +488,67 @@ func (db *DB) queryBuilder(model interface{}, opts ...Option) (*gorm.DB, *QueryO
 	}
 
 	if queryOption.Filters != nil {
-		query = Filters(query, queryOption.Filters)
+		query = db.filters(query, model, queryOption)
 	}
 
 	return query, queryOption
 }
 
-func countBuilder(query *gorm.DB) *gorm.DB {
-	return query.Select("*").Limit(-1).Offset(-1)
+// readQueryBuilder is queryBuilder for reads, which may be served by a replica.
+func (db *DB) readQueryBuilder(model interface{}, opts ...Option) (*gorm.DB, *QueryOption) {
+	return db.queryBuilder(model, append([]Option{func(opts *QueryOption) {
+		opts.read = true
+	}}, opts...)...)
+}
+
+// countBuilder returns the query counting the rows of query, without its
+// ORDER BY, LIMIT and OFFSET. Grouped, distinct and having queries are
+// counted over a derived table, since COUNT(*) counts their source rows,
+// other queries also leave out the select-only joins.
+func countBuilder(query *gorm.DB, queryOption *QueryOption) *gorm.DB {
+	sub := query.Session(&gorm.Session{}).Limit(-1).Offset(-1)
+	delete(sub.Statement.Clauses, "ORDER BY")
+
+	if groupedOrDistinct(sub, queryOption) {
+		return query.Session(&gorm.Session{NewDB: true}).Table("(?) AS count_rows", sub)
+	}
+
+	if len(queryOption.SelectJoin) > 0 {
+		joins := sub.Statement.Joins[:0:0]
+		for _, join := range sub.Statement.Joins {
+			if !isSelectJoin(queryOption, join.Name) {
+				joins = append(joins, join)
+			}
+		}
+		sub.Statement.Joins = joins
+	}
+	return sub.Select("*")
+}
+
+// groupedOrDistinct reports whether the rows of query are groups or distinct
+// values rather than rows of its table.
+func groupedOrDistinct(query *gorm.DB, queryOption *QueryOption) bool {
+	_, grouped := query.Statement.Clauses["GROUP BY"]
+	return grouped || query.Statement.Distinct ||
+		strings.HasPrefix(strings.ToLower(strings.TrimSpace(queryOption.Select.Query)), "distinct")
+}
+
+func isSelectJoin(queryOption *QueryOption, name string) bool {
+	for _, join := range queryOption.SelectJoin {
+		if query, ok := join[0].(string); ok && query == name {
+			return true
+		}
+	}
+	return false
 }
 
 func defaultSort(model interface{}, query *gorm.DB, queryOption *QueryOption) *gorm.DB {
 	if (queryOption.Pageable == nil || queryOption.Pageable.Sort == "") && queryOption.Sort == nil {
 		if primaryKey := getPKName(query.Config,model); primaryKey != "" {
+			// joined tables, e.g. of relation filters, may share the column name
+			if s, err := parseSchema(query, model); err == nil && queryOption.Table == "" && len(query.Statement.Joins) > 0 {
+				primaryKey = query.Statement.Quote(clause.Column{Table: s.Table, Name: primaryKey})
+			}
 			query = query.Order(primaryKey + " desc")
 		}
 	}
@@ -21,6 +21,7 @@ func (db *DB) Create(model interface{}, opts ...Option) error {
 		}
 		return WithStack(err)
 	}
+	db.markWrite(query.Statement.Context)
 	return nil
 }
 
@@ -28,9 +29,9 @@ func (db *DB) Count(model interface{}, opts ...Option) (int, error) {
 	if reflect.TypeOf(model).Kind() != reflect.Ptr || reflect.TypeOf(model).Elem().Kind() != reflect.Struct {
 		return 0, WithStack(ErrorModel)
 	}
-	query, _ := db.queryBuilder(model, opts...)
+	query, queryOpt := db.readQueryBuilder(model, opts...)
 	var count int64 = 0
-	if err := countBuilder(query).Count(&count).Error; err != nil {
+	if err := countBuilder(query, queryOpt).Count(&count).Error; err != nil {
 		return 0, WithStack(err)
 	}
 	return int(count), nil
@@ -41,7 +42,7 @@ func (db *DB) FindById(model interface{}, opts ...Option) error {
 		return WithStack(ErrorModel)
 	}
 
-	query, queryOpt := db.queryBuilder(model, opts...)
+	query, queryOpt := db.readQueryBuilder(model, opts...)
 
 	if _, err := validatePK(model, queryOpt.PrimaryKey); err != nil {
 		return err
@@ -73,7 +74,7 @@ func (db *DB) FindOne(model interface{}, opts ...Option) error {
 		return WithStack(ErrorModel)
 	}
 
-	query, queryOpt := db.queryBuilder(model, opts...)
+	query, queryOpt := db.readQueryBuilder(model, opts...)
 
 	var dest = model
 	if queryOpt.Dest != nil {
@@ -118,7 +119,7 @@ func (db *DB) CloneById(model interface{}, opts ...Option) (interface{}, error)
 	if err := setPKValue(clone, pk.Name, pk.Value); err != nil {
 		return nil, err
 	}
-	opts = append(opts, WithIgnoreOmit())
+	opts = append(opts, WithIgnoreOmit(), WithPrimary())
 	if err := db.FindById(clone, opts...); err != nil {
 		return nil, err
 	}
@@ -130,7 +131,7 @@ func (db *DB) CloneOne(model interface{}, opts ...Option) (interface{}, error) {
 		return nil, WithStack(ErrorModel)
 	}
 	clone := reflect.New(reflect.TypeOf(model).Elem()).Interface()
-	opts = append(opts, WithIgnoreOmit())
+	opts = append(opts, WithIgnoreOmit(), WithPrimary())
 	if err := db.FindOne(clone, opts...); err != nil {
 		return nil, err
 	}
@@ -142,6 +143,7 @@ func (db *DB) delete(model interface{}, query *gorm.DB, queryOpt *QueryOption) e
 	if err := query.Error; err != nil {
 		return WithStack(err)
 	}
+	db.markWrite(query.Statement.Context)
 	if query.RowsAffected == 0 && queryOpt.MustAffected {
 		if err := queryOpt.ErrorNotAffected; err != nil {
 			return err
@@ -222,6 +224,7 @@ func (db *DB) update(model interface{}, updates interface{}, query *gorm.DB, que
 		}
 		return 0, WithStack(err)
 	}
+	db.markWrite(query.Statement.Context)
 
 	if query.RowsAffected == 0 && queryOpt.MustAffected {
 		if err := queryOpt.ErrorNotAffected; err != nil {
@@ -343,7 +346,7 @@ func (db *DB) Find(model interface{}, opts ...Option) error {
 	if reflect.TypeOf(model).Kind() != reflect.Ptr || reflect.TypeOf(model).Elem().Kind() != reflect.Struct {
 		return WithStack(ErrorModel)
 	}
-	query, queryOpt := db.queryBuilder(model, opts...)
+	query, queryOpt := db.readQueryBuilder(model, opts...)
 
 	var dest = model
 	if queryOpt.Dest != nil {
@@ -378,7 +381,7 @@ func (db *DB) FindAllWithModel(model interface{}, opts ...Option) (interface{},
 	if reflect.TypeOf(model).Kind() != reflect.Ptr || reflect.TypeOf(model).Elem().Kind() != reflect.Struct {
 		return nil, WithStack(ErrorModel)
 	}
-	query, queryOpt := db.queryBuilder(model, opts...)
+	query, queryOpt := db.readQueryBuilder(model, opts...)
 	query = defaultSort(model, query, queryOpt)
 	var dest = model
 	if queryOpt.Dest != nil {
@@ -395,24 +398,18 @@ func (db *DB) FindPageWithModel(model interface{}, opts ...Option) (interface{},
 	if reflect.TypeOf(model).Kind() != reflect.Ptr || reflect.TypeOf(model).Elem().Kind() != reflect.Struct {
 		return nil, 0, WithStack(ErrorModel)
 	}
-	query, queryOpt := db.queryBuilder(model, opts...)
+	query, queryOpt := db.readQueryBuilder(model, opts...)
 	query = defaultSort(model, query, queryOpt)
 	var dest = model
 	if queryOpt.Dest != nil {
 		dest = queryOpt.Dest
 	}
 	list := reflect.New(reflect.SliceOf(reflect.TypeOf(dest)))
-	if err := query.Find(list.Interface()).Error; err != nil {
-		return nil, 0, WithStack(err)
-	}
-
-	var total int64 = 0
-	if queryOpt.Pageable != nil {
-		if err := countBuilder(query).Count(&total).Error; err != nil {
-			return nil, 0, err
-		}
+	info, err := db.findPage(model, list.Interface(), query, queryOpt)
+	if err != nil {
+		return nil, 0, err
 	}
-	return list.Interface(), int(total), nil
+	return list.Interface(), info.Total, nil
 }
 
 func (db *DB) FindAll(list interface{}, opts ...Option) error {
@@ -430,7 +427,7 @@ func (db *DB) FindAll(list interface{}, opts ...Option) error {
 	}
 
 	model := reflect.New(elem).Interface()
-	query, queryOpt := db.queryBuilder(model, opts...)
+	query, queryOpt := db.readQueryBuilder(model, opts...)
 	query = defaultSort(model, query, queryOpt)
 
 	//TODO: cache
@@ -444,12 +441,22 @@ func (db *DB) FindAll(list interface{}, opts ...Option) error {
 }
 
 func (db *DB) FindPage(list interface{}, opts ...Option) (int, error) {
+	info, err := db.FindPageInfo(list, opts...)
+	if err != nil {
+		return 0, err
+	}
+	return info.Total, nil
+}
+
+// FindPageInfo is FindPage also reporting how the total was obtained, see
+// WithCountStrategy.
+func (db *DB) FindPageInfo(list interface{}, opts ...Option) (*PageInfo, error) {
 	listT := reflect.TypeOf(list)
 	if listT.Kind() != reflect.Ptr || listT.Elem().Kind() != reflect.Slice {
-		return 0, WithStack(ErrorModel)
+		return nil, WithStack(ErrorModel)
 	}
 	if !(listT.Elem().Elem().Kind() == reflect.Struct || (listT.Elem().Elem().Kind() == reflect.Ptr && listT.Elem().Elem().Elem().Kind() == reflect.Struct)) {
-		return 0, WithStack(ErrorModel)
+		return nil, WithStack(ErrorModel)
 	}
 
 	elem := listT.Elem().Elem()
@@ -458,26 +465,89 @@ func (db *DB) FindPage(list interface{}, opts ...Option) (int, error) {
 	}
 
 	model := reflect.New(elem).Interface()
-	query, queryOpt := db.queryBuilder(model, opts...)
+	query, queryOpt := db.readQueryBuilder(model, opts...)
 	query = defaultSort(model, query, queryOpt)
+	return db.findPage(model, list, query, queryOpt)
+}
 
-	var total int64 = 0
-	if err := query.Find(list).Error; err != nil {
-		return 0, WithStack(err)
+func (db *DB) findPage(model interface{}, list interface{}, query *gorm.DB, queryOpt *QueryOption) (*PageInfo, error) {
+	info, err := db.findPageCount(model, list, query, queryOpt)
+	if err != nil || len(queryOpt.Aggregates) == 0 {
+		return info, err
 	}
-	if queryOpt.Pageable != nil {
-		if err := countBuilder(query).Count(&total).Error; err != nil {
-			return 0, err
+	if info.Summary, err = summarize(model, query, queryOpt); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (db *DB) findPageCount(model interface{}, list interface{}, query *gorm.DB, queryOpt *QueryOption) (*PageInfo, error) {
+	info := &PageInfo{CountStrategy: queryOpt.CountStrategy}
+	if info.CountStrategy == "" {
+		info.CountStrategy = CountExact
+	}
+	pageable := queryOpt.Pageable
+	hasNext := info.CountStrategy == CountHasNext && pageable != nil && pageable.Size > 0
+	if hasNext {
+		query = query.Limit(pageable.Size + 1)
+	}
+
+	listQuery := query
+	if db.deferJoin(query, queryOpt) {
+		listQuery = deferredJoin(model, query, queryOpt)
+	}
+
+	if queryOpt.ConcurrentCount && info.CountStrategy == CountExact && pageable != nil {
+		if _, inTx := query.Statement.ConnPool.(gorm.TxCommitter); !inTx {
+			total, err := findAndCount(list, listQuery, query, queryOpt)
+			if err != nil {
+				return nil, err
+			}
+			info.Total = int(total)
+			return info, nil
 		}
 	}
-	return int(total), nil
+
+	if err := listQuery.Find(list).Error; err != nil {
+		return nil, WithStack(err)
+	}
+	if pageable == nil {
+		return info, nil
+	}
+
+	switch info.CountStrategy {
+	case CountNone:
+	case CountHasNext:
+		listV := reflect.ValueOf(list).Elem()
+		if hasNext && listV.Len() > pageable.Size {
+			listV.Set(listV.Slice(0, pageable.Size))
+			info.HasNext = true
+		}
+		info.Total = listV.Len()
+		if pageable.Page > 1 {
+			info.Total += (pageable.Page - 1) * pageable.Size
+		}
+	case CountEstimate:
+		total, err := estimateCount(model, query, queryOpt)
+		if err != nil {
+			return nil, err
+		}
+		info.Total = int(total)
+	default:
+		var total int64 = 0
+		if err := countBuilder(query, queryOpt).Count(&total).Error; err != nil {
+			return nil, err
+		}
+		info.Total = int(total)
+	}
+	return info, nil
 }
 
 func (db *DB) FindPluck(model interface{}, opts ...Option) error {
 	if reflect.TypeOf(model).Kind() != reflect.Ptr || reflect.TypeOf(model).Elem().Kind() != reflect.Struct {
 		return WithStack(ErrorModel)
 	}
-	query, queryOpt := db.queryBuilder(model, opts...)
+	query, queryOpt := db.readQueryBuilder(model, opts...)
 	if queryOpt.Pluck == nil {
 		return WithStack(ErrorPluck)
 	}
@@ -0,0 +1,90 @@
+package mysql
+
+import (
+	"fmt"
+	"github.com/go-estar/config"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Registry holds the databases of a service by name.
+type Registry struct {
+	mu  sync.RWMutex
+	dbs map[string]*DB
+}
+
+func NewRegistry() *Registry {
+	return &Registry{dbs: map[string]*DB{}}
+}
+
+// NewRegistryWithConfig is OpenRegistryWithConfig panicking on errors.
+func NewRegistryWithConfig(c *config.Config, opts ...ConfigOption) *Registry {
+	r, err := OpenRegistryWithConfig(c, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// OpenRegistryWithConfig opens one DB per database.<name> block that sets a
+// url, e.g. database.order.url, each with its own pool and logger settings
+// which are reloaded like OpenWithConfig does. If any fails to open, those
+// already opened are closed.
+func OpenRegistryWithConfig(c *config.Config, opts ...ConfigOption) (*Registry, error) {
+	r := NewRegistry()
+	for name := range c.GetStringMap("database") {
+		prefix := "database." + name
+		if c.GetString(prefix+".url") == "" {
+			continue
+		}
+		db, err := Open(readConfig(c, prefix, "mysql-"+name), opts...)
+		if err != nil {
+			for _, opened := range r.dbs {
+				opened.close()
+			}
+			return nil, err
+		}
+		r.Set(name, db)
+	}
+	for name, db := range r.dbs {
+		db.watchConfig(c, "database."+name)
+	}
+	return r, nil
+}
+
+// Set registers db as name. Names are case-insensitive, as config keys are
+// lowercased when read.
+func (r *Registry) Set(name string, db *DB) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dbs[strings.ToLower(name)] = db
+}
+
+// Get returns the database registered as name, nil if there is none. Names
+// are case-insensitive, database.orderDB is registered as orderdb.
+func (r *Registry) Get(name string) *DB {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.dbs[strings.ToLower(name)]
+}
+
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.dbs))
+	for name := range r.dbs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewNamedService returns a Service bound to the database registered as name.
+func NewNamedService[T any](r *Registry, name string) *Service[T] {
+	db := r.Get(name)
+	if db == nil {
+		panic(fmt.Sprintf("database %s not registered", name))
+	}
+	return &Service[T]{DB: db}
+}
@@ -0,0 +1,102 @@
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-estar/config"
+	"gorm.io/gorm"
+	"sync"
+	"time"
+)
+
+var (
+	configWatchersMu sync.Mutex
+	configWatchers   = map[*config.Config][]func(fsnotify.Event){}
+)
+
+// OnConfigChange adds fn to the callbacks run when the config file of c
+// changes. viper keeps a single OnConfigChange callback, which OpenWithConfig
+// and OpenRegistryWithConfig take over to reload databases, so applications
+// reading c register their own callbacks here instead of on c.
+func OnConfigChange(c *config.Config, fn func(e fsnotify.Event)) {
+	configWatchersMu.Lock()
+	defer configWatchersMu.Unlock()
+	if _, ok := configWatchers[c]; !ok {
+		c.OnConfigChange(func(e fsnotify.Event) {
+			configWatchersMu.Lock()
+			fns := append([]func(fsnotify.Event){}, configWatchers[c]...)
+			configWatchersMu.Unlock()
+			for _, fn := range fns {
+				fn(e)
+			}
+		})
+	}
+	configWatchers[c] = append(configWatchers[c], fn)
+}
+
+type reloadState struct {
+	mu            sync.Mutex
+	maxIdleConns  int
+	maxOpenConns  int
+	debug         bool
+	slowThreshold time.Duration
+}
+
+func (db *DB) watchConfig(c *config.Config, prefix string) {
+	state := &reloadState{
+		maxIdleConns:  db.config.MaxIdleConns,
+		maxOpenConns:  db.config.MaxOpenConns,
+		debug:         db.config.Debug,
+		slowThreshold: db.config.SlowThreshold,
+	}
+	OnConfigChange(c, func(e fsnotify.Event) {
+		db.config.Logger.Info(fmt.Sprintf("%s reloading from changed config file %s", prefix, e.Name))
+		db.reload(c, prefix, state)
+	})
+}
+
+// reload applies changed pool sizes, debug and slowThreshold settings.
+func (db *DB) reload(c *config.Config, prefix string, state *reloadState) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	maxIdleConns := c.GetInt(configKey(c, prefix, "maxIdleConns"))
+	maxOpenConns := c.GetInt(configKey(c, prefix, "maxOpenConns"))
+	debug := c.GetBool(configKey(c, prefix, "debug"))
+	slowThreshold := c.GetDuration(configKey(c, prefix, "slowThreshold"))
+
+	if maxIdleConns != state.maxIdleConns {
+		db.eachPool(func(pool *sql.DB) { pool.SetMaxIdleConns(maxIdleConns) })
+		db.config.Logger.Info(fmt.Sprintf("%s.maxIdleConns changed %d -> %d", prefix, state.maxIdleConns, maxIdleConns))
+		state.maxIdleConns = maxIdleConns
+	}
+	if maxOpenConns != state.maxOpenConns {
+		db.eachPool(func(pool *sql.DB) { pool.SetMaxOpenConns(maxOpenConns) })
+		db.config.Logger.Info(fmt.Sprintf("%s.maxOpenConns changed %d -> %d", prefix, state.maxOpenConns, maxOpenConns))
+		state.maxOpenConns = maxOpenConns
+	}
+	if debug != state.debug || slowThreshold != state.slowThreshold {
+		conf := *db.config
+		conf.Debug = debug
+		conf.SlowThreshold = slowThreshold
+		db.logger.Set(newGormLogger(&conf))
+		db.config.Logger.Info(fmt.Sprintf("%s.debug changed %v -> %v, %s.slowThreshold changed %v -> %v",
+			prefix, state.debug, debug, prefix, state.slowThreshold, slowThreshold))
+		state.debug = debug
+		state.slowThreshold = slowThreshold
+	}
+}
+
+func (db *DB) eachPool(f func(pool *sql.DB)) {
+	for _, g := range append([]*gorm.DB{db.DB}, db.replicas...) {
+		if pool, err := g.DB(); err == nil {
+			f(pool)
+		}
+	}
+}
+
+// close closes the pools of db and its replicas.
+func (db *DB) close() {
+	db.eachPool(func(pool *sql.DB) { pool.Close() })
+}
@@ -0,0 +1,42 @@
+package mysql
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"math/rand"
+	"sync/atomic"
+)
+
+// ReplicaPolicy picks the replica serving a read.
+type ReplicaPolicy interface {
+	Resolve(replicas []*gorm.DB) *gorm.DB
+}
+
+type RandomPolicy struct {
+}
+
+func (p *RandomPolicy) Resolve(replicas []*gorm.DB) *gorm.DB {
+	return replicas[rand.Intn(len(replicas))]
+}
+
+type RoundRobinPolicy struct {
+	next uint64
+}
+
+func (p *RoundRobinPolicy) Resolve(replicas []*gorm.DB) *gorm.DB {
+	n := atomic.AddUint64(&p.next, 1)
+	return replicas[(n-1)%uint64(len(replicas))]
+}
+
+// replica returns the replica for a read, or nil when it must go to the
+// primary: writes, WithPrimary, anything inside a transaction and reads
+// following a write of the same sticky context or key.
+func (db *DB) replica(ctx context.Context, queryOption *QueryOption) *gorm.DB {
+	if !queryOption.read || queryOption.Primary || len(db.replicas) == 0 || db.InTransaction() {
+		return nil
+	}
+	if db.stickyPrimary(ctx) {
+		return nil
+	}
+	return db.config.ReplicaPolicy.Resolve(db.replicas)
+}
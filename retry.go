@@ -0,0 +1,81 @@
+package mysql
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+type RetryPolicy struct {
+	// MaxAttempts counts the first run, values below 2 disable retrying.
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	// Retryable classifies errors, IsRetryableError when nil.
+	Retryable func(err error) bool
+}
+
+var DefaultRetryPolicy = &RetryPolicy{
+	MaxAttempts: 3,
+	MinBackoff:  50 * time.Millisecond,
+	MaxBackoff:  time.Second,
+	Retryable:   IsRetryableError,
+}
+
+// DefaultConnectRetryPolicy supplies the backoffs a connect retry leaves
+// unset, long enough to wait out a server that starts a few seconds late.
+var DefaultConnectRetryPolicy = &RetryPolicy{
+	MaxAttempts: 10,
+	MinBackoff:  500 * time.Millisecond,
+	MaxBackoff:  5 * time.Second,
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsRetryableError(err)
+}
+
+// backoff doubles MinBackoff per attempt up to MaxBackoff and applies full jitter.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	min, max := p.MinBackoff, p.MaxBackoff
+	if min <= 0 {
+		min = DefaultRetryPolicy.MinBackoff
+	}
+	if max <= 0 {
+		max = DefaultRetryPolicy.MaxBackoff
+	}
+	if max < min {
+		max = min
+	}
+	d := min
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return time.Duration(rand.Int63n(int64(d)) + 1)
+}
+
+// run calls f until it succeeds, returns a non retryable error, exhausts
+// MaxAttempts or ctx is done. A nil policy runs f once.
+func (p *RetryPolicy) run(ctx context.Context, f func() error) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil || p == nil || attempt >= p.MaxAttempts || !p.retryable(err) {
+			return err
+		}
+		timer := time.NewTimer(p.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	p := &RetryPolicy{MinBackoff: 10 * time.Millisecond, MaxBackoff: 80 * time.Millisecond}
+	caps := []time.Duration{10, 20, 40, 80, 80, 80}
+	for i, c := range caps {
+		attempt := i + 1
+		limit := c * time.Millisecond
+		for n := 0; n < 100; n++ {
+			if d := p.backoff(attempt); d <= 0 || d > limit {
+				t.Fatalf("backoff(%d) = %s, want (0, %s]", attempt, d, limit)
+			}
+		}
+	}
+}
+
+func TestRetryBackoffDefaults(t *testing.T) {
+	p := &RetryPolicy{}
+	for n := 0; n < 100; n++ {
+		if d := p.backoff(1); d <= 0 || d > DefaultRetryPolicy.MinBackoff {
+			t.Fatalf("backoff(1) = %s, want (0, %s]", d, DefaultRetryPolicy.MinBackoff)
+		}
+		if d := p.backoff(30); d <= 0 || d > DefaultRetryPolicy.MaxBackoff {
+			t.Fatalf("backoff(30) = %s, want (0, %s]", d, DefaultRetryPolicy.MaxBackoff)
+		}
+	}
+	// a MaxBackoff below MinBackoff is raised to it
+	p = &RetryPolicy{MinBackoff: 20 * time.Millisecond, MaxBackoff: time.Millisecond}
+	for n := 0; n < 100; n++ {
+		if d := p.backoff(5); d <= 0 || d > 20*time.Millisecond {
+			t.Fatalf("backoff(5) = %s, want (0, 20ms]", d)
+		}
+	}
+}
+
+func TestRetryRun(t *testing.T) {
+	errRetry := errors.New("retry")
+	errFatal := errors.New("fatal")
+	retryable := func(err error) bool { return errors.Is(err, errRetry) }
+
+	cases := []struct {
+		name   string
+		policy *RetryPolicy
+		errs   []error
+		calls  int
+		err    error
+	}{
+		{name: "nil policy runs once", errs: []error{errRetry, nil}, calls: 1, err: errRetry},
+		{name: "succeeds after retries", policy: &RetryPolicy{MaxAttempts: 5}, errs: []error{errRetry, errRetry, nil}, calls: 3},
+		{name: "stops on non retryable", policy: &RetryPolicy{MaxAttempts: 5}, errs: []error{errRetry, errFatal, nil}, calls: 2, err: errFatal},
+		{name: "stops at max attempts", policy: &RetryPolicy{MaxAttempts: 3}, errs: []error{errRetry, errRetry, errRetry, nil}, calls: 3, err: errRetry},
+		{name: "max attempts below two", policy: &RetryPolicy{MaxAttempts: 1}, errs: []error{errRetry, nil}, calls: 1, err: errRetry},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if c.policy != nil {
+				c.policy.MinBackoff = time.Microsecond
+				c.policy.MaxBackoff = time.Microsecond
+				c.policy.Retryable = retryable
+			}
+			calls := 0
+			err := c.policy.run(context.Background(), func() error {
+				err := c.errs[calls]
+				calls++
+				return err
+			})
+			if !errors.Is(err, c.err) || (c.err == nil && err != nil) {
+				t.Errorf("err = %v, want %v", err, c.err)
+			}
+			if calls != c.calls {
+				t.Errorf("calls = %d, want %d", calls, c.calls)
+			}
+		})
+	}
+}
+
+func TestRetryRunContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	errRetry := errors.New("retry")
+	p := &RetryPolicy{MaxAttempts: 5, MinBackoff: time.Hour, MaxBackoff: time.Hour, Retryable: func(error) bool { return true }}
+	calls := 0
+	err := p.run(ctx, func() error {
+		calls++
+		return errRetry
+	})
+	if !errors.Is(err, errRetry) || calls != 1 {
+		t.Errorf("err = %v, calls = %d, want %v after 1 call", err, calls, errRetry)
+	}
+}
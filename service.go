@@ -1,6 +1,7 @@
 package mysql
 
 import (
+	"context"
 	"errors"
 	"reflect"
 	"time"
@@ -13,6 +14,13 @@ type Service[T any] struct {
 	TitleQuery string
 }
 
+// WithContext returns a copy of the service bound to ctx.
+func (b *Service[T]) WithContext(ctx context.Context) *Service[T] {
+	s := *b
+	s.DB = b.DB.Ctx(ctx)
+	return &s
+}
+
 func (b *Service[T]) GetPk() (string, error) {
 	if b.Pk != "" {
 		return b.Pk, nil
@@ -118,14 +126,26 @@ func (b *Service[T]) FindAll(opts ...Option) (*[]*T, error) {
 
 func (b *Service[T]) FindPage(opts ...Option) (*PageRes[T], error) {
 	list := b.NewModelList()
-	total, err := b.DB.FindPage(
+	info, err := b.DB.FindPageInfo(
+		list,
+		opts...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &PageRes[T]{Total: info.Total, List: list, CountStrategy: info.CountStrategy, HasNext: info.HasNext, Summary: info.Summary}, nil
+}
+
+func (b *Service[T]) FindCursorPage(opts ...Option) (*CursorPageRes[T], error) {
+	list := b.NewModelList()
+	cursors, err := b.DB.FindCursorPage(
 		list,
 		opts...,
 	)
 	if err != nil {
 		return nil, err
 	}
-	return &PageRes[T]{Total: total, List: list}, nil
+	return &CursorPageRes[T]{List: list, Cursors: *cursors}, nil
 }
 
 func (b *Service[T]) Create(value *T, opts ...Option) (*T, error) {
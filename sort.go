@@ -0,0 +1,165 @@
+package mysql
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+	"strings"
+)
+
+const (
+	SortAsc        = "asc"
+	SortDesc       = "desc"
+	SortNullsFirst = "nullsFirst"
+	SortNullsLast  = "nullsLast"
+)
+
+type SortField struct {
+	Field *schema.Field
+	Desc  bool
+	Nulls string
+}
+
+type SortError struct {
+	Sort string
+	Err  error
+}
+
+func (e *SortError) Error() string {
+	return fmt.Sprintf("sort %q: %v", e.Sort, e.Err)
+}
+
+func (e *SortError) Unwrap() error {
+	return e.Err
+}
+
+// ParseSort parses client supplied sorts like "name,asc;createdAt,desc,nullsLast",
+// a space may separate the parts as well. The gorm order form
+// "created_at desc, id asc" is accepted too: a part that isn't a direction
+// starts the next field. Fields are JSON names, struct field names or columns
+// of s, anything else is rejected.
+func ParseSort(s *schema.Schema, sort string) ([]*SortField, error) {
+	fields := make([]*SortField, 0)
+	for _, item := range strings.Split(sort, ";") {
+		parts := strings.FieldsFunc(item, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		var sortField *SortField
+		for _, part := range parts {
+			switch {
+			case strings.EqualFold(part, SortAsc):
+				if sortField == nil {
+					return nil, WithStack(&SortError{Sort: item, Err: ErrorSortDirection})
+				}
+				sortField.Desc = false
+			case strings.EqualFold(part, SortDesc):
+				if sortField == nil {
+					return nil, WithStack(&SortError{Sort: item, Err: ErrorSortDirection})
+				}
+				sortField.Desc = true
+			case strings.EqualFold(part, SortNullsFirst), strings.EqualFold(part, SortNullsLast):
+				if sortField == nil {
+					return nil, WithStack(&SortError{Sort: item, Err: ErrorSortDirection})
+				}
+				sortField.Nulls = SortNullsFirst
+				if strings.EqualFold(part, SortNullsLast) {
+					sortField.Nulls = SortNullsLast
+				}
+			default:
+				field := lookupField(s, part)
+				if field == nil {
+					return nil, WithStack(&SortError{Sort: item, Err: ErrorSortField})
+				}
+				sortField = &SortField{Field: field}
+				fields = append(fields, sortField)
+			}
+		}
+	}
+	return fields, nil
+}
+
+// sortOrders renders fields as ORDER BY items, emulating NULLS FIRST/LAST
+// with an IS NULL item since MySQL lacks them.
+func sortOrders(query *gorm.DB, table string, fields []*SortField) []string {
+	orders := make([]string, 0, len(fields))
+	for _, field := range fields {
+		column := query.Statement.Quote(clause.Column{Table: table, Name: field.Field.DBName})
+		switch field.Nulls {
+		case SortNullsFirst:
+			orders = append(orders, column+" IS NULL DESC")
+		case SortNullsLast:
+			orders = append(orders, column+" IS NULL")
+		}
+		if field.Desc {
+			orders = append(orders, column+" DESC")
+		} else {
+			orders = append(orders, column)
+		}
+	}
+	return orders
+}
+
+// safeSort orders query by the client supplied sort, failing the query when
+// it names anything but a column of model.
+func (db *DB) safeSort(query *gorm.DB, model interface{}, queryOption *QueryOption, sort string) *gorm.DB {
+	s, err := parseSchema(query, model)
+	if err != nil {
+		query.AddError(WithStack(&SortError{Sort: sort, Err: err}))
+		return query
+	}
+	fields, err := ParseSort(s, sort)
+	if err != nil {
+		query.AddError(err)
+		return query
+	}
+	table := ""
+	if queryOption.Table == "" {
+		table = s.Table
+	}
+	for _, order := range sortOrders(query, table, fields) {
+		query = query.Order(order)
+	}
+	return query
+}
+
+func parseSchema(query *gorm.DB, model interface{}) (*schema.Schema, error) {
+	if model == nil {
+		return nil, ErrorModel
+	}
+	stmt := &gorm.Statement{DB: query}
+	if err := stmt.Parse(model); err != nil {
+		return nil, err
+	}
+	return stmt.Schema, nil
+}
+
+// lookupField finds the column of s named by a JSON name, struct field name or
+// column name, optionally qualified by the table of s. Fields hidden from JSON
+// by `json:"-"` are only found when they carry a filter tag.
+func lookupField(s *schema.Schema, name string) *schema.Field {
+	if i := strings.LastIndex(name, "."); i != -1 {
+		if name[:i] != s.Table {
+			return nil
+		}
+		name = name[i+1:]
+	}
+	if name == "" {
+		return nil
+	}
+	for _, field := range s.Fields {
+		if field.DBName == "" {
+			continue
+		}
+		jsonName := strings.Split(field.Tag.Get("json"), ",")[0]
+		if jsonName == "-" {
+			if _, tagged := field.Tag.Lookup("filter"); !tagged {
+				continue
+			}
+		}
+		if (jsonName != "" && jsonName != "-" && jsonName == name) || field.Name == name || field.DBName == name {
+			return field
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package mysql
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSortWhitelist(t *testing.T) {
+	cases := []struct {
+		name string
+		sort string
+		sql  string
+		err  error
+	}{
+		{name: "pairs", sort: "name,desc;id,asc", sql: "SELECT * FROM `test_orders` ORDER BY `test_orders`.`name` DESC,`test_orders`.`id` LIMIT ?"},
+		{name: "gorm order", sort: "name desc, id asc", sql: "SELECT * FROM `test_orders` ORDER BY `test_orders`.`name` DESC,`test_orders`.`id` LIMIT ?"},
+		{name: "nulls", sort: "amount,asc,nullsLast", sql: "SELECT * FROM `test_orders` ORDER BY `test_orders`.`amount` IS NULL,`test_orders`.`amount` LIMIT ?"},
+		{name: "unknown column", sort: "missing", err: ErrorSortField},
+		{name: "json hidden", sort: "password", err: ErrorSortField},
+		{name: "json dash", sort: "-", err: ErrorSortField},
+		{name: "expression", sort: "(select 1)", err: ErrorSortField},
+		{name: "direction first", sort: "desc,name", err: ErrorSortDirection},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			sql, _, err := dryRunFind(t, newDryRunDB(t), &[]*testOrder{}, WithPageable(&Pageable{Page: 1, Size: 10, Sort: c.sort}))
+			if c.err != nil {
+				if !errors.Is(err, c.err) {
+					t.Fatalf("err = %v, want %v", err, c.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sql != c.sql {
+				t.Errorf("sql = %s\nwant  %s", sql, c.sql)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package mysql
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type stickyScopeKey struct{}
+type stickyKeyKey struct{}
+
+type stickyScope struct {
+	written atomic.Bool
+}
+
+// ContextWithStickyPrimary returns a copy of ctx in which reads go to the
+// primary once a write has been made with it or a context derived from it.
+func ContextWithStickyPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, stickyScopeKey{}, &stickyScope{})
+}
+
+// ContextWithStickyKey returns a copy of ctx bound to key, e.g. a user id.
+// After a write made with key, reads made with the same key go to the
+// primary for Config.StickyWindow, across contexts.
+func ContextWithStickyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, stickyKeyKey{}, key)
+}
+
+type stickyKeys struct {
+	window    time.Duration
+	mu        sync.Mutex
+	until     map[string]time.Time
+	lastSweep time.Time
+}
+
+func newStickyKeys(window time.Duration) *stickyKeys {
+	if window <= 0 {
+		return nil
+	}
+	return &stickyKeys{window: window, until: map[string]time.Time{}, lastSweep: time.Now()}
+}
+
+func (s *stickyKeys) mark(key string) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.until[key] = now.Add(s.window)
+	if now.Sub(s.lastSweep) > s.window {
+		for k, until := range s.until {
+			if now.After(until) {
+				delete(s.until, k)
+			}
+		}
+		s.lastSweep = now
+	}
+}
+
+func (s *stickyKeys) active(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	until, ok := s.until[key]
+	if !ok {
+		return false
+	}
+	if time.Now().After(until) {
+		delete(s.until, key)
+		return false
+	}
+	return true
+}
+
+func (db *DB) markWrite(ctx context.Context) {
+	if ctx == nil {
+		return
+	}
+	if scope, ok := ctx.Value(stickyScopeKey{}).(*stickyScope); ok {
+		scope.written.Store(true)
+	}
+	if key, ok := ctx.Value(stickyKeyKey{}).(string); ok && db.sticky != nil {
+		db.sticky.mark(key)
+	}
+}
+
+func (db *DB) stickyPrimary(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	if scope, ok := ctx.Value(stickyScopeKey{}).(*stickyScope); ok && scope.written.Load() {
+		return true
+	}
+	if key, ok := ctx.Value(stickyKeyKey{}).(string); ok && db.sticky != nil {
+		return db.sticky.active(key)
+	}
+	return false
+}
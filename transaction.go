@@ -1,17 +1,309 @@
 package mysql
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	goLogger "github.com/go-estar/logger"
 	"gorm.io/gorm"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
-func (db *DB) Transaction(f func(tx *gorm.DB) error) (err error) {
-	tx := db.DB.Begin()
+var savePointSeq uint64
+
+type txContextKey struct{}
+
+type txState struct {
+	tx    *gorm.DB
+	hooks *txHooks
+	// pool is the connection pool tx was begun on, only a DB on the same pool
+	// joins tx
+	pool *sql.DB
+	// done is set once the transaction commits or rolls back, before its
+	// callbacks run, shared by the savepoints nested in it
+	done *atomic.Bool
+}
+
+func (s *txState) finished() bool {
+	return s.done != nil && s.done.Load()
+}
+
+// txHooks collects the callbacks registered inside one transaction or savepoint.
+type txHooks struct {
+	mu         sync.Mutex
+	done       bool
+	onCommit   []func()
+	onRollback []func()
+	// logger reports callbacks that panic
+	logger goLogger.Logger
+}
+
+func (db *DB) newTxHooks() *txHooks {
+	hooks := &txHooks{}
+	if db.config != nil {
+		hooks.logger = db.config.Logger
+	}
+	return hooks
+}
+
+func (h *txHooks) add(commit bool, fn func()) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.done {
+		return WithStack(ErrorTransactionDone)
+	}
+	if commit {
+		h.onCommit = append(h.onCommit, fn)
+	} else {
+		h.onRollback = append(h.onRollback, fn)
+	}
+	return nil
+}
+
+func (h *txHooks) take() ([]func(), []func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.done {
+		return nil, nil
+	}
+	h.done = true
+	return h.onCommit, h.onRollback
+}
+
+// finish runs the commit or rollback callbacks in registration order, only
+// the first call has an effect. A callback that panics is logged and does not
+// stop the ones after it.
+func (h *txHooks) finish(committed bool) {
+	if h == nil {
+		return
+	}
+	onCommit, onRollback := h.take()
+	fns := onRollback
+	if committed {
+		fns = onCommit
+	}
+	for _, fn := range fns {
+		h.run(fn)
+	}
+}
+
+func (h *txHooks) run(fn func()) {
+	defer func() {
+		if e := recover(); e != nil && h.logger != nil {
+			h.logger.Error(fmt.Sprintf("%s\n[error] transaction callback panic: %v", FileWithLineNum(), e))
+		}
+	}()
+	fn()
+}
+
+// release hands the callbacks of a released savepoint over to its parent,
+// they run once the outer transaction finishes.
+func (h *txHooks) release(parent *txHooks) {
+	onCommit, onRollback := h.take()
+	for _, fn := range onCommit {
+		parent.add(true, fn)
+	}
+	for _, fn := range onRollback {
+		parent.add(false, fn)
+	}
+}
+
+// OnCommit registers fn to run after the transaction tx belongs to commits.
+func OnCommit(tx *gorm.DB, fn func()) error {
+	return OnCommitContext(contextOf(tx), fn)
+}
+
+// OnRollback registers fn to run after the transaction tx belongs to rolls back.
+func OnRollback(tx *gorm.DB, fn func()) error {
+	return OnRollbackContext(contextOf(tx), fn)
+}
+
+// OnCommitContext registers fn to run after the transaction carried by ctx commits.
+func OnCommitContext(ctx context.Context, fn func()) error {
+	return addTxHook(ctx, true, fn)
+}
+
+// OnRollbackContext registers fn to run after the transaction carried by ctx rolls back.
+func OnRollbackContext(ctx context.Context, fn func()) error {
+	return addTxHook(ctx, false, fn)
+}
+
+func addTxHook(ctx context.Context, commit bool, fn func()) error {
+	state, ok := ctx.Value(txContextKey{}).(*txState)
+	if !ok || state.hooks == nil {
+		return WithStack(ErrorTransactionUnset)
+	}
+	return state.hooks.add(commit, fn)
+}
+
+// ContextWithTx returns a copy of ctx carrying tx, queries built with it by a
+// DB on the pool tx was begun on join tx.
+func ContextWithTx(ctx context.Context, tx *gorm.DB) context.Context {
+	return context.WithValue(ctx, txContextKey{}, &txState{tx: tx, pool: sqlDB(tx)})
+}
+
+// TxFromContext returns the transaction carried by ctx.
+func TxFromContext(ctx context.Context) (*gorm.DB, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	state, ok := ctx.Value(txContextKey{}).(*txState)
+	if !ok || state.tx == nil || state.finished() {
+		return nil, false
+	}
+	return state.tx, true
+}
+
+// txFromContext returns the transaction carried by ctx when it was begun on
+// the pool of db, a transaction of another database or one already finished
+// is ignored.
+func (db *DB) txFromContext(ctx context.Context) (*txState, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	state, ok := ctx.Value(txContextKey{}).(*txState)
+	if !ok || state.tx == nil || state.finished() || state.pool == nil || state.pool != sqlDB(db.DB) {
+		return nil, false
+	}
+	return state, true
+}
+
+// sqlDB returns the pool of g, nil when it has none.
+func sqlDB(g *gorm.DB) *sql.DB {
+	if tx, ok := g.Statement.ConnPool.(*gorm.PreparedStmtTX); ok && tx.PreparedStmtDB != nil {
+		pool, _ := tx.PreparedStmtDB.GetDBConn()
+		return pool
+	}
+	pool, err := g.DB()
+	if err != nil {
+		return nil
+	}
+	return pool
+}
+
+// bindTx returns tx with a context carrying tx itself, so the ctx handed to
+// the closure makes Service calls join the transaction until done is set.
+func bindTx(tx *gorm.DB, hooks *txHooks, pool *sql.DB, done *atomic.Bool) *gorm.DB {
+	state := &txState{hooks: hooks, pool: pool, done: done}
+	tx = tx.WithContext(context.WithValue(contextOf(tx), txContextKey{}, state))
+	state.tx = tx
+	return tx
+}
+
+type TxOptions struct {
+	Isolation sql.IsolationLevel
+	ReadOnly  bool
+	// Timeout bounds a single attempt, the transaction is rolled back once exceeded.
+	Timeout time.Duration
+	// Retry overrides Config.TransactionRetry.
+	Retry *RetryPolicy
+}
+
+type TxOption func(*TxOptions)
+
+func WithTxIsolation(val sql.IsolationLevel) TxOption {
+	return func(opts *TxOptions) {
+		opts.Isolation = val
+	}
+}
+func WithTxReadOnly() TxOption {
+	return func(opts *TxOptions) {
+		opts.ReadOnly = true
+	}
+}
+func WithTxTimeout(val time.Duration) TxOption {
+	return func(opts *TxOptions) {
+		opts.Timeout = val
+	}
+}
+func WithTxRetry(val *RetryPolicy) TxOption {
+	return func(opts *TxOptions) {
+		opts.Retry = val
+	}
+}
+
+// WithTx returns a DB bound to tx. Calling Transaction on it nests the new
+// transaction inside tx using a savepoint.
+func (db *DB) WithTx(tx *gorm.DB) *DB {
+	return db.session(tx)
+}
+
+// InTransaction reports whether db is bound to an open transaction.
+func (db *DB) InTransaction() bool {
+	committer, ok := db.DB.Statement.ConnPool.(gorm.TxCommitter)
+	return ok && committer != nil
+}
+
+func (db *DB) Transaction(f func(tx *gorm.DB) error) error {
+	return db.TransactionWithOptions(f)
+}
+
+// TransactionContext runs f in a transaction carried by the ctx passed to it,
+// DB and Service calls made with that ctx join the transaction.
+func (db *DB) TransactionContext(ctx context.Context, f func(ctx context.Context) error, opts ...TxOption) error {
+	return db.Ctx(ctx).TransactionWithOptions(func(tx *gorm.DB) error {
+		return f(tx.Statement.Context)
+	}, opts...)
+}
+
+// TransactionWithOptions runs f in a transaction configured by opts. Inside an
+// enclosing transaction, bound to db or carried by its context, f runs in a
+// savepoint and opts are ignored.
+func (db *DB) TransactionWithOptions(f func(tx *gorm.DB) error, opts ...TxOption) error {
+	if db.InTransaction() {
+		return db.savePoint(f)
+	}
+	if state, ok := db.txFromContext(db.ctx()); ok {
+		return db.session(state.tx.WithContext(db.ctx())).savePoint(f)
+	}
+	txOpts := &TxOptions{}
+	if db.config != nil {
+		txOpts.Retry = db.config.TransactionRetry
+	}
+	for _, apply := range opts {
+		if apply != nil {
+			apply(txOpts)
+		}
+	}
+	return txOpts.Retry.run(db.ctx(), func() error {
+		return db.transaction(f, txOpts)
+	})
+}
+
+func (db *DB) transaction(f func(tx *gorm.DB) error, opts *TxOptions) (err error) {
+	base := db.DB
+	if opts.Timeout > 0 {
+		ctx, cancel := context.WithTimeout(db.ctx(), opts.Timeout)
+		defer cancel()
+		base = base.WithContext(ctx)
+	}
+	if db.config != nil && db.config.SlowTransactionThreshold > 0 {
+		threshold := db.config.SlowTransactionThreshold
+		caller := FileWithLineNum()
+		timer := time.AfterFunc(threshold, func() {
+			db.config.Logger.Warn(fmt.Sprintf("%s\n[warn] transaction open longer than %v", caller, threshold))
+		})
+		defer timer.Stop()
+	}
+
+	tx := base.Begin(&sql.TxOptions{Isolation: opts.Isolation, ReadOnly: opts.ReadOnly})
+	hooks := db.newTxHooks()
+	done := new(atomic.Bool)
+	// the ctx handed to f stops carrying tx before the callbacks run, so
+	// queries made from them don't use the finished transaction
+	finish := func(committed bool) {
+		done.Store(true)
+		hooks.finish(committed)
+	}
 	defer func() {
 		if e := recover(); e != nil {
 			err = WithStack(errors.New(fmt.Sprint(e)))
 			tx.Rollback()
+			finish(false)
 		}
 	}()
 
@@ -19,12 +311,47 @@ func (db *DB) Transaction(f func(tx *gorm.DB) error) (err error) {
 		return err
 	}
 
-	if err := f(tx); err != nil {
+	if err := f(bindTx(tx, hooks, sqlDB(db.DB), done)); err != nil {
 		tx.Rollback()
+		finish(false)
 		return err
 	}
 
 	if err := tx.Commit().Error; err != nil {
+		finish(false)
+		return err
+	}
+	db.markWrite(db.ctx())
+	finish(true)
+	return nil
+}
+
+func (db *DB) savePoint(f func(tx *gorm.DB) error) (err error) {
+	tx := db.DB.Session(&gorm.Session{NewDB: true})
+	name := "sp" + strconv.FormatUint(atomic.AddUint64(&savePointSeq, 1), 10)
+	if err := tx.SavePoint(name).Error; err != nil {
+		return WithStack(err)
+	}
+	var hooks *txHooks
+	var done *atomic.Bool
+	if state, ok := db.txFromContext(db.ctx()); ok {
+		done = state.done
+		if state.hooks != nil {
+			hooks = db.newTxHooks()
+			defer hooks.release(state.hooks)
+		}
+	}
+	defer func() {
+		if e := recover(); e != nil {
+			err = WithStack(errors.New(fmt.Sprint(e)))
+			tx.RollbackTo(name)
+			hooks.finish(false)
+		}
+	}()
+
+	if err := f(bindTx(tx, hooks, sqlDB(db.DB), done)); err != nil {
+		tx.RollbackTo(name)
+		hooks.finish(false)
 		return err
 	}
 	return nil
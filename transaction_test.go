@@ -0,0 +1,23 @@
+package mysql
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+func TestTxFromContextIgnoresFinished(t *testing.T) {
+	db := newDryRunDB(t)
+	done := new(atomic.Bool)
+	tx := bindTx(db.DB, db.newTxHooks(), sqlDB(db.DB), done)
+	ctx := tx.Statement.Context
+	if _, ok := db.txFromContext(ctx); !ok {
+		t.Fatal("open transaction not joined")
+	}
+	done.Store(true)
+	if _, ok := db.txFromContext(ctx); ok {
+		t.Error("finished transaction joined")
+	}
+	if _, ok := TxFromContext(ctx); ok {
+		t.Error("finished transaction returned by TxFromContext")
+	}
+}
@@ -39,8 +39,43 @@ type PageReq struct {
 }
 
 type PageRes[T any] struct {
-	List  *[]*T `json:"list"`
-	Total int   `json:"total"`
+	List          *[]*T                  `json:"list"`
+	Total         int                    `json:"total"`
+	CountStrategy CountStrategy          `json:"countStrategy,omitempty"`
+	HasNext       bool                   `json:"hasNext,omitempty"`
+	Summary       map[string]interface{} `json:"summary,omitempty"`
+}
+
+type PageInfo struct {
+	// Total is exact, estimated, 0 for CountNone and the rows up to this page
+	// for CountHasNext.
+	Total         int
+	CountStrategy CountStrategy
+	HasNext       bool
+	// Summary holds the values of WithAggregate by name.
+	Summary map[string]interface{}
+}
+
+type CursorPageable struct {
+	// Cursor is a Next or Prev cursor of the previous page, empty for the first page.
+	Cursor string `json:"cursor"`
+	Size   int    `json:"size"`
+	Sort   string `json:"sort"`
+}
+
+type CursorPageReq struct {
+	*CursorPageable `validate:"required"`
+	Filters         map[string]interface{} `json:"filters"`
+}
+
+type Cursors struct {
+	Next string `json:"next"`
+	Prev string `json:"prev"`
+}
+
+type CursorPageRes[T any] struct {
+	List *[]*T `json:"list"`
+	Cursors
 }
 
 type TitleRes struct {